@@ -0,0 +1,193 @@
+// Package backend defines the storage Backend interface which decouples kodofs from the kodo
+// http client, and provides in-memory and local-directory implementations of it.
+package backend
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	xfs "github.com/qiniu/x/http/fs"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// ObjectInfo describes an object stored in a Backend.
+type ObjectInfo struct {
+	Key      string
+	Size     int64
+	Hash     string
+	MimeType string
+	PutTime  time.Time
+}
+
+// ListResult is the result of Backend.List.
+type ListResult struct {
+	Items          []ObjectInfo
+	CommonPrefixes []string
+	Marker         string // marker of the next page, "" means no more items
+}
+
+// Backend represents a storage of objects, such as a kodo bucket.
+// Errors should be fs.ErrNotExist (or wrap it) if an object doesn't exist.
+type Backend interface {
+	// List lists objects with `prefix` after `marker` in lexicographical order of keys.
+	// If `delimiter` isn't empty, keys containing `delimiter` after `prefix` are rolled
+	// up into CommonPrefixes. At most `limit` items and common prefixes are returned (a
+	// default limit is used if it is not positive).
+	List(ctx context.Context, prefix, delimiter, marker string, limit int) (*ListResult, error)
+
+	// Stat returns information of the object `key`.
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+
+	// Open opens the object `key` to read `length` bytes from `offset`. If `length` is
+	// negative, it reads to the end of the object.
+	Open(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+
+	// Put stores `r` (`size` bytes, or unknown if `size` is negative) as the object `key`.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+
+	// Delete deletes the object `key`.
+	Delete(ctx context.Context, key string) error
+}
+
+const (
+	// DefaultListLimit is the default (and max) limit of Backend.List.
+	DefaultListLimit = 1000
+)
+
+// CleanKey converts a path into a key (without leading "/"). It cleans the path first so
+// that the result never escapes the root via "..".
+func CleanKey(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+func dirKey(dir string) string {
+	key := CleanKey(dir)
+	if key != "" {
+		key += "/"
+	}
+	return key
+}
+
+// -----------------------------------------------------------------------------------------
+
+// WalkFunc is the type of the function called by WalkContext to visit each file.
+type WalkFunc = func(path string, info fs.FileInfo, err error) error
+
+// ReaddirContext reads the directory `dir` of a Backend.
+func ReaddirContext(ctx context.Context, be Backend, dir string) (fis []fs.FileInfo, err error) {
+	dir = dirKey(dir)
+	marker := ""
+	fis = make([]fs.FileInfo, 0, 64)
+	for {
+		ret, e := be.List(ctx, dir, "/", marker, DefaultListLimit)
+		if e != nil {
+			return nil, e
+		}
+		for _, item := range ret.Items {
+			fi := xfs.NewFileInfo(item.Key[len(dir):], item.Size)
+			fi.Mtime = item.PutTime
+			fis = append(fis, fi)
+		}
+		for _, key := range ret.CommonPrefixes {
+			name := key[len(dir) : len(key)-1]
+			fis = append(fis, xfs.NewDirInfo(name))
+		}
+		if ret.Marker == "" {
+			break
+		}
+		marker = ret.Marker
+	}
+	return
+}
+
+// WalkContext walks all files under the directory `dir` of a Backend. It stops if `fn`
+// returns an error, and returns the error.
+func WalkContext(ctx context.Context, be Backend, dir string, fn WalkFunc) (err error) {
+	dir = dirKey(dir)
+	marker := ""
+	for {
+		ret, e := be.List(ctx, dir, "", marker, DefaultListLimit)
+		if e != nil {
+			return e
+		}
+		for _, item := range ret.Items {
+			fi := xfs.NewFileInfo(item.Key[len(dir):], item.Size)
+			fi.Mtime = item.PutTime
+			if err = fn("/"+item.Key, fi, nil); err != nil {
+				return
+			}
+		}
+		if ret.Marker == "" {
+			break
+		}
+		marker = ret.Marker
+	}
+	return
+}
+
+// -----------------------------------------------------------------------------------------
+
+type subBackend struct {
+	be   Backend
+	root string // "<prefix>/"
+}
+
+// Sub returns a view of a Backend rooted at `prefix`. All operations of the returned
+// Backend are scoped to the prefix, and keys can't escape it via "..".
+func Sub(be Backend, prefix string) Backend {
+	root := dirKey(prefix)
+	if root == "" {
+		return be
+	}
+	if sub, ok := be.(*subBackend); ok {
+		return &subBackend{sub.be, sub.root + root}
+	}
+	return &subBackend{be, root}
+}
+
+func (p *subBackend) List(ctx context.Context, prefix, delimiter, marker string, limit int) (*ListResult, error) {
+	ret, err := p.be.List(ctx, p.root+prefix, delimiter, marker, limit)
+	if err != nil {
+		return nil, err
+	}
+	n := len(p.root)
+	items := make([]ObjectInfo, len(ret.Items))
+	for i, item := range ret.Items {
+		item.Key = item.Key[n:]
+		items[i] = item
+	}
+	prefixes := make([]string, len(ret.CommonPrefixes))
+	for i, cp := range ret.CommonPrefixes {
+		prefixes[i] = cp[n:]
+	}
+	return &ListResult{items, prefixes, ret.Marker}, nil
+}
+
+func (p *subBackend) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, err := p.be.Stat(ctx, p.root+CleanKey(key))
+	if err != nil {
+		return nil, err
+	}
+	ret := *info
+	ret.Key = ret.Key[len(p.root):]
+	return &ret, nil
+}
+
+func (p *subBackend) Open(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	return p.be.Open(ctx, p.root+CleanKey(key), offset, length)
+}
+
+func (p *subBackend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	return p.be.Put(ctx, p.root+CleanKey(key), r, size)
+}
+
+func (p *subBackend) Delete(ctx context.Context, key string) error {
+	return p.be.Delete(ctx, p.root+CleanKey(key))
+}
+
+// -----------------------------------------------------------------------------------------
@@ -0,0 +1,80 @@
+package backend_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/xushiwei/kodofs/backend"
+	"github.com/xushiwei/kodofs/cached"
+)
+
+func testBackend(t *testing.T, be backend.Backend) {
+	ctx := context.Background()
+	for _, key := range []string{"a/b.txt", "a/c/d.txt", "e.txt"} {
+		if err := be.Put(ctx, key, strings.NewReader(key), int64(len(key))); err != nil {
+			t.Fatal("Put:", err)
+		}
+	}
+	fis, err := backend.ReaddirContext(ctx, be, "/a")
+	if err != nil || len(fis) != 2 || fis[0].Name() != "b.txt" || !fis[1].IsDir() {
+		t.Fatal("ReaddirContext:", fis, err)
+	}
+	sub := backend.Sub(be, "a/../a/c")
+	info, err := sub.Stat(ctx, "/../d.txt")
+	if err != nil || info.Key != "d.txt" || info.Size != 9 {
+		t.Fatal("Sub.Stat:", info, err)
+	}
+	rc, err := sub.Open(ctx, "d.txt", 2, 3)
+	if err != nil {
+		t.Fatal("Sub.Open:", err)
+	}
+	b, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(b) != "c/d" {
+		t.Fatal("Sub.Open:", string(b))
+	}
+	n := 0
+	err = backend.WalkContext(ctx, be, "/", func(path string, info fs.FileInfo, err error) error {
+		n++
+		return nil
+	})
+	if err != nil || n != 3 {
+		t.Fatal("WalkContext:", n, err)
+	}
+	if err = be.Delete(ctx, "e.txt"); err != nil {
+		t.Fatal("Delete:", err)
+	}
+	if _, err = be.Stat(ctx, "e.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("Stat:", err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testBackend(t, backend.NewMemory())
+}
+
+func TestLocal(t *testing.T) {
+	testBackend(t, backend.NewLocal(t.TempDir()))
+}
+
+func TestCachedFS(t *testing.T) {
+	be := backend.NewMemory()
+	be.Put(context.Background(), "a/b.txt", strings.NewReader("hello"), 5)
+	fsys, err := cached.NewBackendFS(t.TempDir(), be, true)
+	if err != nil {
+		t.Fatal("NewBackendFS:", err)
+	}
+	dir, err := fsys.Open("/")
+	if err != nil {
+		t.Fatal("Open /:", err)
+	}
+	fis, err := dir.Readdir(-1)
+	dir.Close()
+	if err != nil || len(fis) != 1 || fis[0].Name() != "a" || !fis[0].IsDir() {
+		t.Fatal("Readdir /:", fis, err)
+	}
+}
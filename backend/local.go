@@ -0,0 +1,152 @@
+package backend
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xushiwei/kodofs/kodoutil"
+)
+
+// -----------------------------------------------------------------------------------------
+
+type localBackend struct {
+	dir string
+}
+
+// NewLocal creates a Backend stored in the local directory `dir`. An object `key` is
+// stored as the file `<dir>/<key>`, and its PutTime is the modification time of the file.
+func NewLocal(dir string) Backend {
+	return &localBackend{dir}
+}
+
+func (p *localBackend) file(key string) (string, error) {
+	if key == "" || strings.HasSuffix(key, "/") || path.Clean("/"+key) != "/"+key {
+		return "", &fs.PathError{Op: "open", Path: key, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(p.dir, filepath.FromSlash(key)), nil
+}
+
+func (p *localBackend) info(key, file string, fi fs.FileInfo) (*ObjectInfo, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	hash, err := kodoutil.Etag(f)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{
+		Key:      key,
+		Size:     fi.Size(),
+		Hash:     hash,
+		MimeType: mimeTypeOf(key),
+		PutTime:  fi.ModTime(),
+	}, nil
+}
+
+func (p *localBackend) List(ctx context.Context, prefix, delimiter, marker string, limit int) (*ListResult, error) {
+	var objs []ObjectInfo
+	err := filepath.Walk(p.dir, func(file string, fi fs.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(p.dir, file)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			info, err := p.info(key, file, fi)
+			if err != nil {
+				return err
+			}
+			objs = append(objs, *info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Key < objs[j].Key })
+	return listSorted(objs, prefix, delimiter, marker, limit), nil
+}
+
+func (p *localBackend) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	file, err := p.file(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, &fs.PathError{Op: "stat", Path: key, Err: fs.ErrNotExist}
+	}
+	return p.info(key, file, fi)
+}
+
+func (p *localBackend) Open(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	file, err := p.file(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return f, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, length), f}, nil
+}
+
+func (p *localBackend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	file, err := p.file(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp := file + ".put~"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp, file)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+func (p *localBackend) Delete(ctx context.Context, key string) error {
+	file, err := p.file(key)
+	if err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
+
+// -----------------------------------------------------------------------------------------
@@ -0,0 +1,166 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xushiwei/kodofs/kodoutil"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// listSorted implements Backend.List by objects sorted by key.
+func listSorted(objs []ObjectInfo, prefix, delimiter, marker string, limit int) *ListResult {
+	if limit <= 0 || limit > DefaultListLimit {
+		limit = DefaultListLimit
+	}
+	ret := &ListResult{}
+	last, n := "", 0
+	for _, obj := range objs {
+		key := obj.Key
+		if !strings.HasPrefix(key, prefix) || key <= marker {
+			continue
+		}
+		if delimiter != "" && strings.HasSuffix(marker, delimiter) && strings.HasPrefix(key, marker) {
+			continue // marker is a common prefix
+		}
+		cp := ""
+		if delimiter != "" {
+			if pos := strings.Index(key[len(prefix):], delimiter); pos >= 0 {
+				cp = key[:len(prefix)+pos+len(delimiter)]
+				if cp == last {
+					continue
+				}
+			}
+		}
+		if n == limit {
+			ret.Marker = last
+			break
+		}
+		if cp != "" {
+			ret.CommonPrefixes = append(ret.CommonPrefixes, cp)
+			last = cp
+		} else {
+			ret.Items = append(ret.Items, obj)
+			last = key
+		}
+		n++
+	}
+	return ret
+}
+
+func mimeTypeOf(key string) string {
+	if typ := mime.TypeByExtension(path.Ext(key)); typ != "" {
+		return typ
+	}
+	return "application/octet-stream"
+}
+
+func section(data []byte, offset, length int64) []byte {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	data = data[offset:]
+	if length >= 0 && length < int64(len(data)) {
+		data = data[:length]
+	}
+	return data
+}
+
+// -----------------------------------------------------------------------------------------
+
+type memObject struct {
+	info ObjectInfo
+	data []byte
+}
+
+type memBackend struct {
+	objs map[string]*memObject
+	mu   sync.RWMutex
+}
+
+// NewMemory creates an in-memory Backend.
+func NewMemory() Backend {
+	return &memBackend{objs: make(map[string]*memObject)}
+}
+
+func (p *memBackend) List(ctx context.Context, prefix, delimiter, marker string, limit int) (*ListResult, error) {
+	p.mu.RLock()
+	objs := make([]ObjectInfo, 0, len(p.objs))
+	for key, o := range p.objs {
+		if strings.HasPrefix(key, prefix) {
+			objs = append(objs, o.info)
+		}
+	}
+	p.mu.RUnlock()
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Key < objs[j].Key })
+	return listSorted(objs, prefix, delimiter, marker, limit), nil
+}
+
+func (p *memBackend) get(key string) (*memObject, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if o, ok := p.objs[key]; ok {
+		return o, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: key, Err: fs.ErrNotExist}
+}
+
+func (p *memBackend) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	o, err := p.get(key)
+	if err != nil {
+		return nil, err
+	}
+	info := o.info
+	return &info, nil
+}
+
+func (p *memBackend) Open(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	o, err := p.get(key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(section(o.data, offset, length))), nil
+}
+
+func (p *memBackend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	hash, _ := kodoutil.Etag(bytes.NewReader(data))
+	o := &memObject{
+		info: ObjectInfo{
+			Key:      key,
+			Size:     int64(len(data)),
+			Hash:     hash,
+			MimeType: mimeTypeOf(key),
+			PutTime:  time.Now(),
+		},
+		data: data,
+	}
+	p.mu.Lock()
+	p.objs[key] = o
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *memBackend) Delete(ctx context.Context, key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.objs[key]; !ok {
+		return &fs.PathError{Op: "delete", Path: key, Err: fs.ErrNotExist}
+	}
+	delete(p.objs, key)
+	return nil
+}
+
+// -----------------------------------------------------------------------------------------
@@ -1,19 +1,82 @@
 package cached
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"os"
 
-	"github.com/qiniu/x/http/fs/cached/remote"
+	"github.com/qiniu/x/http/fsx"
 	"github.com/xushiwei/kodofs"
+	"github.com/xushiwei/kodofs/backend"
+	"github.com/xushiwei/kodofs/kodoutil"
 )
 
 // -----------------------------------------------------------------------------------------
 
+func init() {
+	kodofs.RegisterCache(openURL)
+}
+
+// openURL opens a cached file system of `bkt` by cache params of a kodofs url (see
+// kodoutil.URL). The snapshot is imported only if the cache directory doesn't exist yet.
+func openURL(ctx context.Context, u *kodoutil.URL, bkt *kodofs.Bucket) (http.FileSystem, fsx.Closer, error) {
+	opts := &Options{
+		CacheFile:            u.CacheFile,
+		Offline:              u.Offline,
+		DirTTL:               u.DirTTL,
+		FileTTL:              u.FileTTL,
+		StaleWhileRevalidate: u.Stale,
+		MaxBytes:             u.MaxBytes,
+		MaxFiles:             u.MaxFiles,
+	}
+	switch u.Evict {
+	case "", "lru":
+		opts.Evict = EvictLRU
+	case "lfu":
+		opts.Evict = EvictLFU
+	default:
+		return nil, nil, fmt.Errorf("cached: unknown evict policy %q", u.Evict)
+	}
+	if u.Snapshot != "" {
+		if _, err := os.Stat(u.Cache); os.IsNotExist(err) {
+			if err = importFile(u.Cache, u.Snapshot); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	p, err := New(u.Cache, bkt, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, p.Close, nil
+}
+
+func importFile(local, snapshot string) error {
+	f, err := os.Open(snapshot)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = Import(local, f)
+	return err
+}
+
 // NewFS creates a cached http.FileSystem to speed up listing directories and accessing file
 // contents (optional, only when `cacheFile` is true). If `offline` is true, the cached http.FileSystem
-// doesn't access `bkt *kodofs.Bucket`.
+// doesn't access `bkt *kodofs.Bucket`. Use New to specify more options, such as TTLs.
 func NewFS(local string, bkt *kodofs.Bucket, cacheFile bool, offline ...bool) (fs http.FileSystem, err error) {
-	return remote.NewCached(local, bkt, nil, cacheFile, offline...)
+	opts := &Options{CacheFile: cacheFile}
+	if offline != nil {
+		opts.Offline = offline[0]
+	}
+	return New(local, bkt, opts)
+}
+
+// NewBackendFS creates a cached http.FileSystem of a storage Backend (see NewFS). Files are read
+// from the Backend directly.
+func NewBackendFS(local string, be backend.Backend, cacheFile bool, offline ...bool) (fs http.FileSystem, err error) {
+	return NewFS(local, kodofs.FromBackend(be, "", nil), cacheFile, offline...)
 }
 
 // -----------------------------------------------------------------------------------------
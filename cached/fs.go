@@ -0,0 +1,592 @@
+package cached
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	xfs "github.com/qiniu/x/http/fs"
+	xcached "github.com/qiniu/x/http/fs/cached"
+	xdir "github.com/qiniu/x/http/fs/cached/dir"
+	"github.com/qiniu/x/http/fs/cached/remote"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	// ErrOffline is returned when a file isn't cached and the file system is offline.
+	ErrOffline = xcached.ErrOffline
+)
+
+var (
+	debugNet bool
+)
+
+const (
+	DbgFlagNetwork = 1 << iota
+	DbgFlagAll     = DbgFlagNetwork
+)
+
+func SetDebug(dbgFlags int) {
+	debugNet = (dbgFlags & DbgFlagNetwork) != 0
+}
+
+// -----------------------------------------------------------------------------------------
+
+const (
+	dirListFile        = remote.SysFilePrefix + "ls" // its mtime is when the directory was listed
+	validFilePrefix    = remote.SysFilePrefix + "v." // its mtime is when the file was validated
+	downloadFilePrefix = remote.SysFilePrefix + "dl."
+)
+
+var (
+	errTooLarge = errors.New("file is too large to cache")
+
+	// invalidTime is the mtime of invalidated directory listings and files.
+	invalidTime = time.Unix(0, 0)
+)
+
+// Options represents the options of a cached file system.
+type Options struct {
+	// CacheFile specifies to cache file contents (not only directory listings).
+	CacheFile bool
+
+	// Offline specifies to use the local cache only, without accessing the remote.
+	Offline bool
+
+	// DirTTL is how long a cached directory listing is fresh. Zero means forever.
+	DirTTL time.Duration
+
+	// FileTTL is how long a cached file is fresh. An expired file is revalidated by its size
+	// and modification time, and is downloaded again only if it was changed. Zero means
+	// forever.
+	FileTTL time.Duration
+
+	// StaleWhileRevalidate specifies to serve expired directory listings and files from the
+	// cache, and to refresh them in background.
+	StaleWhileRevalidate bool
+
+	// MaxBytes is the max total size of cached file contents. Zero means unlimited. Files
+	// larger than MaxBytes are never cached.
+	MaxBytes int64
+
+	// MaxFiles is the max number of files whose contents are cached. Zero means unlimited.
+	MaxFiles int
+
+	// Evict is the policy to evict cached file contents when MaxBytes or MaxFiles is
+	// exceeded. An evicted file is still listed, and its content is downloaded again when
+	// it is accessed.
+	Evict EvictPolicy
+}
+
+// FS is a cached http.FileSystem which caches directory listings (and file contents if
+// Options.CacheFile is true) of a remote http.FileSystem in a local directory.
+type FS struct {
+	local  string
+	remote http.FileSystem
+	opts   Options
+	group  singleflight.Group
+	index  *index
+
+	refreshing sync.WaitGroup
+}
+
+// New creates a cached file system of `remote` in the local directory `local`. File contents
+// cached in the directory before are accounted (and evicted if the cache exceeds its limits).
+func New(local string, remote http.FileSystem, opts *Options) (*FS, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if err := os.MkdirAll(local, 0755); err != nil {
+		return nil, err
+	}
+	p := &FS{local: local, remote: remote, opts: *opts, index: newIndex()}
+	if err := p.index.rebuild(local); err != nil {
+		return nil, err
+	}
+	p.evict("")
+	return p, nil
+}
+
+// Close waits for background refreshes to finish. The file system shouldn't be used after it
+// is closed.
+func (p *FS) Close() error {
+	p.refreshing.Wait()
+	return nil
+}
+
+// Remote returns the remote file system.
+func (p *FS) Remote() http.FileSystem {
+	return p.remote
+}
+
+func (p *FS) localPath(name string) string {
+	return filepath.Join(p.local, filepath.FromSlash(name))
+}
+
+// Open implements net/http.FileSystem.Open (https://pkg.go.dev/net/http#FileSystem).
+func (p *FS) Open(name string) (f http.File, err error) {
+	name = path.Clean("/" + name)
+	localFile := p.localPath(name)
+	fi, err := os.Lstat(localFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return
+		}
+		if fi, err = p.lstatMissing(name); err != nil {
+			return
+		}
+	}
+	switch {
+	case fi.IsDir():
+		return p.openDir(name, localFile)
+	case fi.Mode()&fs.ModeSymlink != 0: // stub of a remote file
+		if p.opts.Offline {
+			return nil, ErrOffline
+		}
+		return p.openRemote(name, localFile)
+	}
+	return p.openFile(name, localFile)
+}
+
+// lstatMissing checks a file missing in the cache by the listing of its parent directory.
+func (p *FS) lstatMissing(name string) (fs.FileInfo, error) {
+	dir := path.Dir(name)
+	mtime, ok := modTime(filepath.Join(p.localPath(dir), dirListFile))
+	if !ok || !p.opts.Offline && expired(mtime, p.opts.DirTTL) {
+		if p.opts.Offline {
+			return nil, ErrOffline
+		}
+		if _, err := p.syncDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	fi, err := os.Lstat(p.localPath(name))
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return fi, nil
+}
+
+func (p *FS) openDir(name, localDir string) (http.File, error) {
+	mtime, ok := modTime(filepath.Join(localDir, dirListFile))
+	switch {
+	case !ok:
+		if p.opts.Offline {
+			return nil, ErrOffline
+		}
+		if _, err := p.syncDir(name); err != nil {
+			return nil, err
+		}
+	case p.opts.Offline || !expired(mtime, p.opts.DirTTL):
+	case p.opts.StaleWhileRevalidate:
+		p.refresh("d"+name, func() error {
+			_, err := p.syncDir(name)
+			return err
+		})
+	default:
+		if _, err := p.syncDir(name); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.Open(localDir)
+	if err != nil {
+		return nil, err
+	}
+	fis, err := f.Readdir(-1)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	n := 0
+	for _, fi := range fis {
+		name := fi.Name()
+		if strings.HasPrefix(name, remote.SysFilePrefix) { // skip fscache system files
+			continue
+		}
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			fi = readStubFile(filepath.Join(localDir, name), fi)
+		}
+		fis[n] = fi
+		n++
+	}
+	return xfs.Dir(f, fis[:n]), nil
+}
+
+func (p *FS) openFile(name, localFile string) (http.File, error) {
+	if !p.opts.Offline {
+		mtime, ok := modTime(validFile(localFile))
+		if !ok && p.opts.FileTTL > 0 || ok && expired(mtime, p.opts.FileTTL) {
+			if p.opts.StaleWhileRevalidate {
+				p.refresh("f"+name, func() error {
+					return p.revalidate(name)
+				})
+			} else if err := p.revalidate(name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	f, err := os.Open(localFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return p.Open(name) // replaced by a stub or a directory
+		}
+		return nil, err
+	}
+	p.index.hit(name)
+	return f, nil
+}
+
+func (p *FS) openRemote(name, localFile string) (http.File, error) {
+	p.index.miss()
+	if !p.opts.CacheFile {
+		return p.remote.Open(name)
+	}
+	_, err, _ := p.group.Do("g"+name, func() (interface{}, error) {
+		return nil, p.download(name, localFile)
+	})
+	if err != nil {
+		if err == errTooLarge {
+			return p.remote.Open(name)
+		}
+		return nil, err
+	}
+	f, err := os.Open(localFile)
+	if err != nil && os.IsNotExist(err) { // evicted
+		return p.remote.Open(name)
+	}
+	return f, err
+}
+
+// refresh runs `fn` in background, unless there is a running refresh of the same `key`.
+func (p *FS) refresh(key string, fn func() error) {
+	p.refreshing.Add(1)
+	ch := p.group.DoChan("r"+key, func() (interface{}, error) {
+		err := fn()
+		if err != nil && debugNet {
+			log.Println("[WARN] cached.refresh", key[1:], "failed:", err)
+		}
+		return nil, err
+	})
+	go func() {
+		<-ch
+		p.refreshing.Done()
+	}()
+}
+
+// -----------------------------------------------------------------------------------------
+
+// syncDir lists the directory `name` of the remote, and updates stubs of the local directory.
+func (p *FS) syncDir(name string) ([]fs.FileInfo, error) {
+	ret, err, _ := p.group.Do("d"+name, func() (interface{}, error) {
+		return p.doSyncDir(name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret.([]fs.FileInfo), nil
+}
+
+func (p *FS) doSyncDir(name string) ([]fs.FileInfo, error) {
+	fis, err := p.readdirRemote(name)
+	if err == nil && len(fis) == 0 && name != "/" { // kodo doesn't have empty directories
+		err = &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if err != nil {
+		if name != "/" && errors.Is(err, fs.ErrNotExist) {
+			p.removeLocal(name)
+		}
+		return nil, err
+	}
+	if debugNet {
+		log.Println("[INFO] ==> cached.syncDir", name, "-", len(fis), "items")
+	}
+	return fis, p.updateDir(name, fis)
+}
+
+// updateDir updates stubs of the local directory `name` by its listing `fis`. Entries with
+// invalid names (eg. the empty name of a folder placeholder "dir/") are skipped, and stubs
+// which fail to be written are counted (see Stats.StubErrors) instead of failing the listing.
+func (p *FS) updateDir(name string, fis []fs.FileInfo) (err error) {
+	localDir := p.localPath(name)
+	if err = os.MkdirAll(localDir, 0755); err != nil {
+		return
+	}
+	entries, err := os.ReadDir(localDir)
+	if err != nil {
+		return
+	}
+	locals := make(map[string]fs.DirEntry, len(entries))
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), remote.SysFilePrefix) {
+			locals[e.Name()] = e
+		}
+	}
+	for _, fi := range fis {
+		fname := fi.Name()
+		if !validName(fname) {
+			continue
+		}
+		localFile := filepath.Join(localDir, fname)
+		if e, ok := locals[fname]; ok {
+			delete(locals, fname)
+			if upToDate(localFile, e, fi) {
+				continue
+			}
+			p.removeLocal(path.Join(name, fname))
+		}
+		if e := remote.WriteStubFile(localFile, fi); e != nil {
+			p.index.stubError()
+			if debugNet {
+				log.Println("[WARN] cached.updateDir", path.Join(name, fname), "failed:", e)
+			}
+		}
+	}
+	for fname := range locals { // removed from the remote
+		p.removeLocal(path.Join(name, fname))
+	}
+	return remote.TouchDirCached(localDir)
+}
+
+// validName checks if `name` is a valid name of a directory entry.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\")
+}
+
+// revalidate checks if the cached file `name` was changed in the remote. If so, it replaces
+// the cached content with a stub.
+func (p *FS) revalidate(name string) error {
+	_, err, _ := p.group.Do("f"+name, func() (interface{}, error) {
+		localFile := p.localPath(name)
+		fi, err := p.statRemote(name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				p.removeLocal(name)
+			}
+			return nil, err
+		}
+		if lfi, e := os.Lstat(localFile); e == nil && lfi.Mode().IsRegular() && sameFile(lfi, fi) {
+			return nil, touch(validFile(localFile), time.Now())
+		}
+		p.removeLocal(name)
+		return nil, remote.WriteStubFile(localFile, fi)
+	})
+	return err
+}
+
+func (p *FS) download(name, localFile string) (err error) {
+	f, err := p.remote.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return
+	}
+	if fi.IsDir() {
+		return &fs.PathError{Op: "download", Path: name, Err: fs.ErrInvalid}
+	}
+	if p.opts.MaxBytes > 0 && fi.Size() > p.opts.MaxBytes {
+		return errTooLarge
+	}
+	tmpFile := filepath.Join(filepath.Dir(localFile), downloadFilePrefix+fi.Name())
+	if err = xfs.Download(tmpFile, f); err == nil {
+		os.Chtimes(tmpFile, fi.ModTime(), fi.ModTime()) // restore mtime
+		err = os.Rename(tmpFile, localFile)
+	}
+	if err != nil {
+		os.Remove(tmpFile)
+		return
+	}
+	if debugNet {
+		log.Println("[INFO] ==> cached.download", name)
+	}
+	p.index.add(name, fi.Size())
+	p.evict(name)
+	return touch(validFile(localFile), time.Now())
+}
+
+type readdirContexter interface {
+	ReaddirContext(ctx context.Context, dir string) (fis []fs.FileInfo, err error)
+}
+
+func (p *FS) readdirRemote(name string) ([]fs.FileInfo, error) {
+	if r, ok := p.remote.(readdirContexter); ok {
+		return r.ReaddirContext(context.Background(), name)
+	}
+	f, err := p.remote.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdir(-1)
+}
+
+type statContexter interface {
+	StatContext(ctx context.Context, name string) (fi fs.FileInfo, err error)
+}
+
+func (p *FS) statRemote(name string) (fs.FileInfo, error) {
+	if s, ok := p.remote.(statContexter); ok {
+		return s.StatContext(context.Background(), name)
+	}
+	f, err := p.remote.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// -----------------------------------------------------------------------------------------
+
+// Invalidate marks the cached directory listing or file `name` expired, so that it will be
+// refreshed from the remote when it is accessed next time. If `name` isn't cached, the
+// listing of its parent directory is marked expired, so that a new remote file is found.
+func (p *FS) Invalidate(name string) error {
+	name = path.Clean("/" + name)
+	localFile := p.localPath(name)
+	fi, err := os.Lstat(localFile)
+	if err != nil {
+		if os.IsNotExist(err) && name != "/" {
+			return p.Invalidate(path.Dir(name))
+		}
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return invalidate(localFile, fi)
+}
+
+// InvalidatePrefix marks all cached directory listings and files whose names have `prefix`
+// expired, as well as the listing of the directory which contains the prefix.
+func (p *FS) InvalidatePrefix(prefix string) error {
+	prefix = "/" + strings.TrimPrefix(prefix, "/")
+	dir := prefix[:strings.LastIndexByte(prefix, '/')+1]
+	if err := p.Invalidate(dir); err != nil {
+		return err
+	}
+	root := p.localPath(dir)
+	return filepath.Walk(root, func(file string, fi fs.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if file == root || strings.HasPrefix(fi.Name(), remote.SysFilePrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(p.local, file)
+		if err != nil {
+			return err
+		}
+		name := "/" + filepath.ToSlash(rel)
+		if strings.HasPrefix(name, prefix) {
+			return invalidate(file, fi)
+		}
+		if fi.IsDir() && !strings.HasPrefix(prefix, name+"/") {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+func invalidate(localFile string, fi fs.FileInfo) error {
+	switch {
+	case fi.IsDir():
+		err := os.Chtimes(filepath.Join(localFile, dirListFile), invalidTime, invalidTime)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	case fi.Mode().IsRegular():
+		return touch(validFile(localFile), invalidTime)
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------------------
+
+func validFile(localFile string) string {
+	return filepath.Join(filepath.Dir(localFile), validFilePrefix+filepath.Base(localFile))
+}
+
+func modTime(file string) (time.Time, bool) {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return fi.ModTime(), true
+}
+
+func expired(mtime time.Time, ttl time.Duration) bool {
+	return !mtime.After(invalidTime) || ttl > 0 && time.Since(mtime) > ttl
+}
+
+func touch(file string, mtime time.Time) error {
+	if err := os.Chtimes(file, mtime, mtime); !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.WriteFile(file, nil, 0666); err != nil {
+		return err
+	}
+	return os.Chtimes(file, mtime, mtime)
+}
+
+// removeLocal removes a cached file (or directory) with its system files.
+func (p *FS) removeLocal(name string) {
+	localFile := p.localPath(name)
+	os.RemoveAll(localFile)
+	os.Remove(validFile(localFile))
+	p.index.remove(name)
+}
+
+// upToDate checks if a local entry matches the remote file `fi`.
+func upToDate(localFile string, e fs.DirEntry, fi fs.FileInfo) bool {
+	if fi.IsDir() || e.IsDir() {
+		return fi.IsDir() && e.IsDir()
+	}
+	lfi, err := e.Info()
+	if err != nil {
+		return false
+	}
+	if lfi.Mode()&fs.ModeSymlink != 0 {
+		return sameFile(readStubFile(localFile, lfi), fi)
+	}
+	if sameFile(lfi, fi) {
+		touch(validFile(localFile), time.Now()) // validated by the listing
+		return true
+	}
+	return false
+}
+
+// readStubFile reads the remote file info from a stub (see remote.WriteStubFile).
+func readStubFile(localFile string, fi fs.FileInfo) fs.FileInfo {
+	dest, e1 := os.Readlink(localFile)
+	if e1 == nil {
+		if b, e2 := base64.URLEncoding.DecodeString(dest); e2 == nil {
+			if ret, e3 := xdir.FileInfoFrom(b); e3 == nil {
+				return ret
+			}
+		}
+	}
+	return fi
+}
+
+// sameFile compares sizes and modification times (in seconds, since http headers don't
+// carry subsecond times) of files.
+func sameFile(a, b fs.FileInfo) bool {
+	return a.Size() == b.Size() && a.ModTime().Unix() == b.ModTime().Unix()
+}
+
+// -----------------------------------------------------------------------------------------
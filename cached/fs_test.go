@@ -0,0 +1,301 @@
+package cached_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiniu/x/token/protected"
+	"github.com/xushiwei/kodofs"
+	"github.com/xushiwei/kodofs/backend"
+	"github.com/xushiwei/kodofs/cached"
+	"github.com/xushiwei/kodofs/kodoutil"
+)
+
+func readFile(t *testing.T, fsys http.FileSystem, name string) string {
+	f, err := fsys.Open(name)
+	if err != nil {
+		t.Fatal("Open:", name, err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal("ReadAll:", name, err)
+	}
+	return string(b)
+}
+
+func readdir(t *testing.T, fsys http.FileSystem, name string) []string {
+	f, err := fsys.Open(name)
+	if err != nil {
+		t.Fatal("Open:", name, err)
+	}
+	defer f.Close()
+	fis, err := f.Readdir(-1)
+	if err != nil {
+		t.Fatal("Readdir:", name, err)
+	}
+	names := make([]string, len(fis))
+	for i, fi := range fis {
+		names[i] = fi.Name()
+	}
+	return names
+}
+
+func put(be backend.Backend, key, data string) {
+	be.Put(context.Background(), key, strings.NewReader(data), int64(len(data)))
+}
+
+func TestInvalidate(t *testing.T) {
+	be := backend.NewMemory()
+	put(be, "site/a/b.txt", "hello")
+	fsys, err := cached.New(t.TempDir(), kodofs.FromBackend(be, "", nil), &cached.Options{CacheFile: true})
+	if err != nil {
+		t.Fatal("New:", err)
+	}
+	if v := readFile(t, fsys, "/site/a/b.txt"); v != "hello" { // cold cache
+		t.Fatal("readFile:", v)
+	}
+	if _, err = fsys.Open("/site/a/none.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("Open none.txt:", err)
+	}
+
+	put(be, "site/a/b.txt", "hello, world")
+	put(be, "site/a/c.txt", "c")
+	if v := readFile(t, fsys, "/site/a/b.txt"); v != "hello" { // cached
+		t.Fatal("readFile:", v)
+	}
+	if names := readdir(t, fsys, "/site/a"); len(names) != 1 {
+		t.Fatal("readdir:", names)
+	}
+	time.Sleep(time.Second) // modification times are compared in seconds
+	put(be, "site/a/b.txt", "hello, world")
+	if err = fsys.InvalidatePrefix("/site/"); err != nil {
+		t.Fatal("InvalidatePrefix:", err)
+	}
+	if v := readFile(t, fsys, "/site/a/b.txt"); v != "hello, world" {
+		t.Fatal("readFile:", v)
+	}
+	if names := readdir(t, fsys, "/site/a"); len(names) != 2 {
+		t.Fatal("readdir:", names)
+	}
+}
+
+func TestDirTTL(t *testing.T) {
+	be := backend.NewMemory()
+	put(be, "a.txt", "a")
+	fsys, err := cached.New(t.TempDir(), kodofs.FromBackend(be, "", nil), &cached.Options{DirTTL: time.Nanosecond})
+	if err != nil {
+		t.Fatal("New:", err)
+	}
+	if names := readdir(t, fsys, "/"); len(names) != 1 {
+		t.Fatal("readdir:", names)
+	}
+	put(be, "b.txt", "b")
+	if names := readdir(t, fsys, "/"); len(names) != 2 {
+		t.Fatal("readdir:", names)
+	}
+	if v := readFile(t, fsys, "/b.txt"); v != "b" {
+		t.Fatal("readFile:", v)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	be := backend.NewMemory()
+	put(be, "a.txt", "aaaa")
+	put(be, "b.txt", "bbbb")
+	put(be, "c.txt", "cccc")
+	local := t.TempDir()
+	remote := kodofs.FromBackend(be, "", nil)
+	fsys, err := cached.New(local, remote, &cached.Options{CacheFile: true, MaxBytes: 10})
+	if err != nil {
+		t.Fatal("New:", err)
+	}
+	readFile(t, fsys, "/a.txt")
+	readFile(t, fsys, "/b.txt")
+	readFile(t, fsys, "/a.txt") // hit, so b.txt is the least recently used one
+	readFile(t, fsys, "/c.txt")
+	stats := fsys.Stats()
+	if stats.Hits != 1 || stats.Misses != 3 || stats.Evictions != 1 || stats.FilesCached != 2 || stats.BytesCached != 8 {
+		t.Fatal("Stats:", stats)
+	}
+	if names := readdir(t, fsys, "/"); len(names) != 3 {
+		t.Fatal("readdir:", names)
+	}
+	if v := readFile(t, fsys, "/b.txt"); v != "bbbb" { // downloaded again
+		t.Fatal("readFile:", v)
+	}
+
+	fsys, err = cached.New(local, remote, &cached.Options{CacheFile: true, MaxFiles: 1})
+	if err != nil {
+		t.Fatal("New:", err)
+	}
+	if stats = fsys.Stats(); stats.FilesCached != 1 || stats.BytesCached != 4 || stats.Evictions != 1 {
+		t.Fatal("Stats after rebuild:", stats)
+	}
+}
+
+func TestWarm(t *testing.T) {
+	be := backend.NewMemory()
+	put(be, "site/index.html", "index")
+	put(be, "site/img/a.png", "aaaa")
+	put(be, "site/img/b.png", "bbbb")
+	put(be, "other.txt", "other")
+	local := t.TempDir()
+	remote := kodofs.FromBackend(be, "", nil)
+	fsys, err := cached.New(local, remote, &cached.Options{CacheFile: true})
+	if err != nil {
+		t.Fatal("New:", err)
+	}
+	n := 0
+	progress, err := fsys.Warm(context.Background(), "/site", &cached.WarmOptions{
+		Files:       true,
+		Concurrency: 1,
+		MaxBytes:    10,
+		Progress:    func(p *cached.WarmProgress) { n++ },
+	})
+	if err != nil {
+		t.Fatal("Warm:", err)
+	}
+	if progress.Dirs != 2 || progress.Files != 2 || progress.Skipped != 1 || progress.Bytes != 8 || n != 5 {
+		t.Fatal("Warm:", *progress, n)
+	}
+
+	offline, err := cached.New(local, remote, &cached.Options{CacheFile: true, Offline: true})
+	if err != nil {
+		t.Fatal("New:", err)
+	}
+	if names := readdir(t, offline, "/site/img"); len(names) != 2 {
+		t.Fatal("readdir:", names)
+	}
+	if stats := offline.Stats(); stats.FilesCached != 2 {
+		t.Fatal("Stats:", stats)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	be := backend.NewMemory()
+	put(be, "site/index.html", "index")
+	put(be, "site/img/a.png", "aaaa")
+	fsys, err := cached.New(t.TempDir(), kodofs.FromBackend(be, "", nil), nil)
+	if err != nil {
+		t.Fatal("New:", err)
+	}
+	var buf bytes.Buffer
+	m, err := fsys.Export(context.Background(), "/site", &buf)
+	if err != nil || len(m.Files) != 2 || m.Files[0].Name != "/site/img/a.png" {
+		t.Fatal("Export:", m, err)
+	}
+	if info, _ := be.Stat(context.Background(), "site/img/a.png"); m.Files[0].Hash != info.Hash {
+		t.Fatal("Export: hash mismatched")
+	}
+
+	tampered := bytes.Replace(buf.Bytes(), []byte("aaaa"), []byte("bbbb"), 1)
+	local := t.TempDir()
+	if _, err = cached.Import(local, bytes.NewReader(tampered)); err == nil {
+		t.Fatal("Import: tampered snapshot accepted")
+	}
+	if _, err = os.Lstat(filepath.Join(local, "site", "img", "a.png")); !os.IsNotExist(err) {
+		t.Fatal("Import: tampered file installed", err)
+	}
+	if _, err = cached.Import(local, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal("Import:", err)
+	}
+	offline, err := cached.New(local, nil, &cached.Options{Offline: true})
+	if err != nil {
+		t.Fatal("New:", err)
+	}
+	if names := readdir(t, offline, "/site"); len(names) != 2 {
+		t.Fatal("readdir:", names)
+	}
+	if v := readFile(t, offline, "/site/img/a.png"); v != "aaaa" {
+		t.Fatal("readFile:", v)
+	}
+}
+
+func TestFolderPlaceholder(t *testing.T) {
+	be := backend.NewMemory()
+	put(be, "dir/", "")
+	put(be, "dir/a.txt", "a")
+	fsys, err := cached.New(t.TempDir(), kodofs.FromBackend(be, "", nil), nil)
+	if err != nil {
+		t.Fatal("New:", err)
+	}
+	if names := readdir(t, fsys, "/dir"); len(names) != 1 || names[0] != "a.txt" {
+		t.Fatal("readdir:", names)
+	}
+
+	put(be, "dir/b.txt", "b")
+	if _, err = fsys.Open("/dir/b.txt"); !errors.Is(err, fs.ErrNotExist) { // listing cached
+		t.Fatal("Open b.txt:", err)
+	}
+	if err = fsys.Invalidate("/dir/b.txt"); err != nil { // invalidates the listing of /dir
+		t.Fatal("Invalidate:", err)
+	}
+	if v := readFile(t, fsys, "/dir/b.txt"); v != "b" {
+		t.Fatal("readFile:", v)
+	}
+}
+
+func TestOpenURL(t *testing.T) {
+	protected.KeySalt = "cached-test"
+	protected.EnvKeyName = "CACHED_TEST_KEY"
+	t.Setenv(protected.EnvKeyName, "random-key")
+
+	be := backend.NewMemory()
+	put(be, "site/index.html", "index")
+	fsys, err := cached.New(t.TempDir(), kodofs.FromBackend(be, "", nil), nil)
+	if err != nil {
+		t.Fatal("New:", err)
+	}
+	snapshot := filepath.Join(t.TempDir(), "site.tar")
+	f, err := os.Create(snapshot)
+	if err != nil {
+		t.Fatal("Create:", err)
+	}
+	_, err = fsys.Export(context.Background(), "/site", f)
+	f.Close()
+	if err != nil {
+		t.Fatal("Export:", err)
+	}
+
+	u := &kodoutil.URL{
+		Bucket: "bkt", AccessKey: "ak", SecretKey: "sk", Host: "http://127.0.0.1:1",
+		Cache: filepath.Join(t.TempDir(), "cache"), CacheFile: true, Offline: true,
+		DirTTL: time.Minute, Evict: "lfu", Snapshot: snapshot,
+	}
+	url, err := u.Encode()
+	if err != nil {
+		t.Fatal("Encode:", err)
+	}
+	ctx := context.Background()
+	cfs, closer, err := kodofs.Open(ctx, url)
+	if err != nil {
+		t.Fatal("Open:", err)
+	}
+	if _, ok := cfs.(*cached.FS); !ok || closer == nil {
+		t.Fatalf("Open: %T", cfs)
+	}
+	if v := readFile(t, cfs, "/site/index.html"); v != "index" { // seeded by the snapshot
+		t.Fatal("readFile:", v)
+	}
+	if err = closer(); err != nil {
+		t.Fatal("Close:", err)
+	}
+
+	u.Evict = "mru"
+	if url, err = u.Encode(); err != nil {
+		t.Fatal("Encode:", err)
+	}
+	if _, _, err = kodofs.Open(ctx, url); err == nil {
+		t.Fatal("Open: unknown evict policy accepted")
+	}
+}
@@ -0,0 +1,238 @@
+package cached
+
+import (
+	"container/list"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/x/http/fs/cached/remote"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// EvictPolicy is the policy to choose which cached files are evicted when the cache is full.
+type EvictPolicy int
+
+const (
+	// EvictLRU evicts the least recently used file first.
+	EvictLRU EvictPolicy = iota
+	// EvictLFU evicts the least frequently used file first.
+	EvictLFU
+)
+
+// Stats represents statistics of a cached file system.
+type Stats struct {
+	Hits        int64 // number of opens served by cached file contents
+	Misses      int64 // number of opens of files whose contents aren't cached
+	Evictions   int64 // number of evicted files
+	FilesCached int64 // number of files whose contents are cached
+	BytesCached int64 // total size of cached file contents
+	StubErrors  int64 // number of listed remote files whose stubs failed to be written
+}
+
+// HitRate returns the ratio of hits to all opens of files.
+func (p *Stats) HitRate() float64 {
+	if n := p.Hits + p.Misses; n > 0 {
+		return float64(p.Hits) / float64(n)
+	}
+	return 0
+}
+
+type cacheEntry struct {
+	name  string
+	size  int64
+	hits  int64
+	atime time.Time
+	elem  *list.Element
+}
+
+// index tracks cached file contents to evict them when the cache exceeds its limits.
+type index struct {
+	entries map[string]*cacheEntry
+	lru     *list.List // front is the most recently used
+	stats   Stats
+	mutex   sync.Mutex
+}
+
+func newIndex() *index {
+	return &index{entries: make(map[string]*cacheEntry), lru: list.New()}
+}
+
+// rebuild rebuilds the index from cached file contents of the local directory.
+func (p *index) rebuild(local string) error {
+	var entries []*cacheEntry
+	err := filepath.Walk(local, func(file string, fi fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(fi.Name(), remote.SysFilePrefix) {
+			if fi.IsDir() && file != local { // eg. a staging directory left by Import
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(local, file)
+		if err != nil {
+			return err
+		}
+		atime := fi.ModTime()
+		if vt, ok := modTime(validFile(file)); ok && vt.After(atime) {
+			atime = vt
+		}
+		entries = append(entries, &cacheEntry{name: "/" + filepath.ToSlash(rel), size: fi.Size(), atime: atime})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].atime.After(entries[j].atime) })
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, e := range entries {
+		e.elem = p.lru.PushBack(e)
+		p.entries[e.name] = e
+		p.stats.FilesCached++
+		p.stats.BytesCached += e.size
+	}
+	return nil
+}
+
+// add adds (or updates) a cached file as the most recently used one.
+func (p *index) add(name string, size int64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if e, ok := p.entries[name]; ok {
+		p.stats.BytesCached += size - e.size
+		e.size, e.atime = size, time.Now()
+		p.lru.MoveToFront(e.elem)
+		return
+	}
+	e := &cacheEntry{name: name, size: size, atime: time.Now()}
+	e.elem = p.lru.PushFront(e)
+	p.entries[name] = e
+	p.stats.FilesCached++
+	p.stats.BytesCached += size
+}
+
+func (p *index) hit(name string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.stats.Hits++
+	if e, ok := p.entries[name]; ok {
+		e.hits++
+		e.atime = time.Now()
+		p.lru.MoveToFront(e.elem)
+	}
+}
+
+func (p *index) miss() {
+	p.mutex.Lock()
+	p.stats.Misses++
+	p.mutex.Unlock()
+}
+
+func (p *index) stubError() {
+	p.mutex.Lock()
+	p.stats.StubErrors++
+	p.mutex.Unlock()
+}
+
+// remove removes `name` and all files under it from the index.
+func (p *index) remove(name string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if e, ok := p.entries[name]; ok {
+		p.removeEntry(e)
+		return
+	}
+	dir := strings.TrimSuffix(name, "/") + "/"
+	for key, e := range p.entries {
+		if strings.HasPrefix(key, dir) {
+			p.removeEntry(e)
+		}
+	}
+}
+
+func (p *index) removeEntry(e *cacheEntry) {
+	delete(p.entries, e.name)
+	p.lru.Remove(e.elem)
+	p.stats.FilesCached--
+	p.stats.BytesCached -= e.size
+}
+
+// victims removes entries from the index until it is within the limits, and returns them.
+// The entry `keep` isn't chosen.
+func (p *index) victims(maxBytes int64, maxFiles int, policy EvictPolicy, keep string) (ret []*cacheEntry) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for maxBytes > 0 && p.stats.BytesCached > maxBytes || maxFiles > 0 && p.stats.FilesCached > int64(maxFiles) {
+		var victim *cacheEntry
+		for at := p.lru.Back(); at != nil; at = at.Prev() {
+			e := at.Value.(*cacheEntry)
+			if e.name == keep {
+				continue
+			}
+			if victim == nil || policy == EvictLFU && e.hits < victim.hits {
+				victim = e
+			}
+			if policy == EvictLRU {
+				break
+			}
+		}
+		if victim == nil {
+			break
+		}
+		p.removeEntry(victim)
+		p.stats.Evictions++
+		ret = append(ret, victim)
+	}
+	return
+}
+
+func (p *index) snapshot() Stats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.stats
+}
+
+// -----------------------------------------------------------------------------------------
+
+// Stats returns statistics of the cached file system.
+func (p *FS) Stats() Stats {
+	return p.index.snapshot()
+}
+
+// evict evicts cached file contents until the cache is within its limits. An evicted file is
+// replaced by a stub, so it is still listed in its directory.
+func (p *FS) evict(keep string) {
+	opts := &p.opts
+	if opts.MaxBytes <= 0 && opts.MaxFiles <= 0 {
+		return
+	}
+	for _, e := range p.index.victims(opts.MaxBytes, opts.MaxFiles, opts.Evict, keep) {
+		localFile := p.localPath(e.name)
+		fi, err := os.Lstat(localFile)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		tmpFile := filepath.Join(filepath.Dir(localFile), downloadFilePrefix+fi.Name())
+		if err = remote.WriteStubFile(tmpFile, fi); err == nil {
+			err = os.Rename(tmpFile, localFile)
+		}
+		if err != nil {
+			os.Remove(tmpFile)
+			os.Remove(localFile)
+		}
+		os.Remove(validFile(localFile))
+	}
+}
+
+// -----------------------------------------------------------------------------------------
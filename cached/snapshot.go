@@ -0,0 +1,254 @@
+package cached
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	xfs "github.com/qiniu/x/http/fs"
+	"github.com/qiniu/x/http/fs/cached/remote"
+	"github.com/xushiwei/kodofs/kodoutil"
+)
+
+const (
+	snapshotManifest = "manifest.json"
+	snapshotFiles    = "files" // file `<name>` is stored as `files<name>` in a snapshot
+
+	importDirPrefix = remote.SysFilePrefix + "import." // staging directory of Import
+)
+
+// -----------------------------------------------------------------------------------------
+
+// Manifest describes files of a snapshot (see FS.Export).
+type Manifest struct {
+	Dir     string         `json:"dir"`
+	Created time.Time      `json:"created"`
+	Files   []ManifestFile `json:"files"`
+}
+
+// ManifestFile describes a file of a snapshot.
+type ManifestFile struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Hash    string    `json:"hash"` // kodo etag, see kodoutil.Etag
+	PutTime time.Time `json:"putTime"`
+}
+
+// Export writes a tar snapshot of all files under the directory `dir` into `w`. Files are
+// read through the cached file system, so it can export an offline cache. The snapshot
+// consists of file contents and a manifest (the last entry) with their hashes and PutTimes.
+// Use Import to seed a cache directory from the snapshot.
+func (p *FS) Export(ctx context.Context, dir string, w io.Writer) (*Manifest, error) {
+	dir = path.Clean("/" + dir)
+	m := &Manifest{Dir: dir, Created: time.Now().UTC()}
+	tw := tar.NewWriter(w)
+	err := p.walk(ctx, dir, func(name string) error {
+		mf, err := p.exportFile(tw, name)
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, *mf)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     snapshotManifest,
+		Size:     int64(len(b)),
+		Mode:     0644,
+		ModTime:  m.Created,
+	}
+	if err = tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	if _, err = tw.Write(b); err != nil {
+		return nil, err
+	}
+	return m, tw.Close()
+}
+
+func (p *FS) exportFile(tw *tar.Writer, name string) (*ManifestFile, error) {
+	f, err := p.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     snapshotFiles + name,
+		Size:     fi.Size(),
+		Mode:     0644,
+		ModTime:  fi.ModTime(),
+		Format:   tar.FormatPAX, // keep subsecond PutTimes
+	}
+	if err = tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	hash, err := kodoutil.Etag(io.TeeReader(io.LimitReader(f, fi.Size()), tw))
+	if err != nil {
+		return nil, err
+	}
+	return &ManifestFile{Name: name, Size: fi.Size(), Hash: hash, PutTime: fi.ModTime().UTC()}, nil
+}
+
+// walk walks all files under the directory `dir` of the cached file system in order.
+func (p *FS) walk(ctx context.Context, dir string, fn func(name string) error) error {
+	f, err := p.Open(dir)
+	if err != nil {
+		return err
+	}
+	fis, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	sort.Slice(fis, func(i, j int) bool { return fis[i].Name() < fis[j].Name() })
+	for _, fi := range fis {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		name := path.Join(dir, fi.Name())
+		if fi.IsDir() {
+			err = p.walk(ctx, name, fn)
+		} else {
+			err = fn(name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------------------
+
+// Import seeds the cache directory `local` from a snapshot written by FS.Export. Files are
+// extracted to a staging directory and verified against the manifest first, and only files
+// of the manifest are installed into the cache after all of them are verified. Afterwards
+// the directory of the snapshot can be accessed by a cached file system in offline mode.
+func Import(local string, r io.Reader) (*Manifest, error) {
+	p, err := New(local, nil, &Options{Offline: true})
+	if err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(local, importDirPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	var m *Manifest
+	hashes := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		switch {
+		case hdr.Name == snapshotManifest:
+			m = new(Manifest)
+			if err = json.NewDecoder(tr).Decode(m); err != nil {
+				return nil, err
+			}
+		case hdr.Typeflag == tar.TypeReg && strings.HasPrefix(hdr.Name, snapshotFiles+"/"):
+			name := path.Clean(hdr.Name[len(snapshotFiles):])
+			if hashes[name], err = stageFile(stagingPath(staging, name), tr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if m == nil {
+		return nil, errors.New("cached.Import: manifest not found")
+	}
+	m.Dir = path.Clean("/" + m.Dir)
+	for _, f := range m.Files {
+		if !strings.HasPrefix(f.Name, strings.TrimSuffix(m.Dir, "/")+"/") || path.Clean(f.Name) != f.Name {
+			return nil, fmt.Errorf("cached.Import: invalid file name %s", f.Name)
+		}
+		if hash, ok := hashes[f.Name]; !ok || hash != f.Hash {
+			return nil, fmt.Errorf("cached.Import: hash of %s mismatched", f.Name)
+		}
+	}
+
+	dirs := map[string][]fs.FileInfo{m.Dir: nil}
+	for _, f := range m.Files {
+		if err = p.installFile(stagingPath(staging, f.Name), f); err != nil {
+			return nil, err
+		}
+		fi := xfs.NewFileInfo(path.Base(f.Name), f.Size)
+		fi.Mtime = f.PutTime
+		addToDirs(dirs, m.Dir, f.Name, fi)
+	}
+	names := make([]string, 0, len(dirs))
+	for name := range dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err = p.updateDir(name, dirs[name]); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func stagingPath(staging, name string) string {
+	return filepath.Join(staging, filepath.FromSlash(name))
+}
+
+// stageFile writes a file of the snapshot to the staging directory, and returns its hash.
+func stageFile(file string, r io.Reader) (hash string, err error) {
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return
+	}
+	hash, err = kodoutil.Etag(io.TeeReader(r, f))
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return
+}
+
+// installFile moves a verified file from the staging directory into the cache.
+func (p *FS) installFile(stagedFile string, f ManifestFile) (err error) {
+	localFile := p.localPath(f.Name)
+	if err = os.MkdirAll(filepath.Dir(localFile), 0755); err != nil {
+		return
+	}
+	os.Chtimes(stagedFile, f.PutTime, f.PutTime)
+	os.RemoveAll(localFile)
+	if err = os.Rename(stagedFile, localFile); err != nil {
+		return
+	}
+	p.index.add(f.Name, f.Size)
+	return touch(validFile(localFile), time.Now())
+}
+
+// -----------------------------------------------------------------------------------------
@@ -0,0 +1,236 @@
+package cached
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"sync"
+
+	xfs "github.com/qiniu/x/http/fs"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// WarmOptions represents the options of FS.Warm.
+type WarmOptions struct {
+	// Files specifies to prefetch file contents (Options.CacheFile must be true), not only
+	// directory listings.
+	Files bool
+
+	// Concurrency is the max number of concurrent downloads (default: 4).
+	Concurrency int
+
+	// MaxBytes is the byte budget of prefetching file contents. Zero means unlimited. Files
+	// which don't fit in the remaining budget are skipped.
+	MaxBytes int64
+
+	// Progress is called (serially) each time a directory or file is prefetched.
+	Progress func(p *WarmProgress)
+}
+
+// WarmProgress represents progress of FS.Warm.
+type WarmProgress struct {
+	Name    string // name of the directory or file which was prefetched just now
+	Err     error  // error of prefetching Name
+	Dirs    int    // number of prefetched directory listings
+	Files   int    // number of prefetched (or already cached) files
+	Bytes   int64  // total size of prefetched files
+	Skipped int    // number of files skipped because of the byte budget
+	Errors  int    // number of files failed to prefetch
+}
+
+type walkContexter interface {
+	WalkContext(ctx context.Context, dir string, fn func(path string, info fs.FileInfo, err error) error) error
+}
+
+// Warm prefetches directory listings (and file contents if opts.Files is true) under the
+// directory `dir`, so that the cache is warm before it is accessed. The remote is walked by
+// its WalkContext method if it has one (such as kodofs.Bucket), or by reading directories
+// recursively.
+func (p *FS) Warm(ctx context.Context, dir string, opts *WarmOptions) (*WarmProgress, error) {
+	if p.opts.Offline {
+		return nil, ErrOffline
+	}
+	if opts == nil {
+		opts = &WarmOptions{}
+	}
+	if opts.Files && !p.opts.CacheFile {
+		return nil, errors.New("cached.Warm: file contents aren't cached")
+	}
+	dir = path.Clean("/" + dir)
+	dirs := map[string][]fs.FileInfo{dir: nil}
+	var files []fileToWarm
+	err := p.walkRemote(ctx, dir, func(name string, fi fs.FileInfo) {
+		files = append(files, fileToWarm{name, fi.Size()})
+		addToDirs(dirs, dir, name, fi)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 && dir != "/" { // kodo doesn't have empty directories
+		return nil, &fs.PathError{Op: "warm", Path: dir, Err: fs.ErrNotExist}
+	}
+
+	w := &warmer{opts: opts}
+	names := make([]string, 0, len(dirs))
+	for name := range dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err = ctx.Err(); err != nil {
+			return &w.progress, err
+		}
+		fis := dirs[name]
+		_, err, _ := p.group.Do("d"+name, func() (interface{}, error) {
+			return nil, p.updateDir(name, fis)
+		})
+		if err != nil {
+			return &w.progress, err
+		}
+		w.done(name, func(s *WarmProgress) { s.Dirs++ })
+	}
+	if opts.Files {
+		p.warmFiles(ctx, w, files)
+	}
+	return &w.progress, ctx.Err()
+}
+
+// addToDirs adds the file `name` (under `dir`) into listings of its parent directories.
+func addToDirs(dirs map[string][]fs.FileInfo, dir, name string, fi fs.FileInfo) {
+	for {
+		parent := path.Dir(name)
+		fis, ok := dirs[parent]
+		dirs[parent] = append(fis, fi)
+		if ok || parent == dir {
+			break
+		}
+		name, fi = parent, xfs.NewDirInfo(path.Base(parent))
+	}
+}
+
+type fileToWarm struct {
+	name string
+	size int64
+}
+
+type warmer struct {
+	opts     *WarmOptions
+	progress WarmProgress
+	budget   int64 // bytes reserved from opts.MaxBytes
+	mutex    sync.Mutex
+}
+
+func (p *warmer) done(name string, update func(s *WarmProgress)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.progress.Name, p.progress.Err = name, nil
+	update(&p.progress)
+	if p.opts.Progress != nil {
+		p.opts.Progress(&p.progress)
+	}
+}
+
+// reserve reserves `size` bytes from the byte budget.
+func (p *warmer) reserve(size int64) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if max := p.opts.MaxBytes; max > 0 && p.budget+size > max {
+		return false
+	}
+	p.budget += size
+	return true
+}
+
+func (p *FS) warmFiles(ctx context.Context, w *warmer, files []fileToWarm) {
+	n := w.opts.Concurrency
+	if n <= 0 {
+		n = 4
+	}
+	ch := make(chan fileToWarm)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for f := range ch {
+				p.warmFile(w, f)
+			}
+		}()
+	}
+	for _, f := range files {
+		select {
+		case ch <- f:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(ch)
+	wg.Wait()
+}
+
+func (p *FS) warmFile(w *warmer, f fileToWarm) {
+	localFile := p.localPath(f.name)
+	if fi, err := os.Lstat(localFile); err == nil && fi.Mode().IsRegular() {
+		w.done(f.name, func(s *WarmProgress) { s.Files++ }) // already cached
+		return
+	}
+	if !w.reserve(f.size) {
+		w.done(f.name, func(s *WarmProgress) { s.Skipped++ })
+		return
+	}
+	_, err, _ := p.group.Do("g"+f.name, func() (interface{}, error) {
+		return nil, p.download(f.name, localFile)
+	})
+	w.done(f.name, func(s *WarmProgress) {
+		switch err {
+		case nil:
+			s.Files++
+			s.Bytes += f.size
+		case errTooLarge:
+			s.Skipped++
+		default:
+			s.Err = err
+			s.Errors++
+		}
+	})
+}
+
+// walkRemote walks all files under the directory `dir` of the remote.
+func (p *FS) walkRemote(ctx context.Context, dir string, fn func(name string, fi fs.FileInfo)) error {
+	if w, ok := p.remote.(walkContexter); ok {
+		return w.WalkContext(ctx, dir, func(name string, fi fs.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			base := xfs.NewFileInfo(path.Base(name), fi.Size()) // fi.Name() is relative to dir
+			base.Mtime = fi.ModTime()
+			fn(name, base)
+			return ctx.Err()
+		})
+	}
+	fis, err := p.readdirRemote(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		name := path.Join(dir, fi.Name())
+		if fi.IsDir() {
+			err = p.walkRemote(ctx, name, fn)
+		} else {
+			fn(name, fi)
+			err = ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------------------
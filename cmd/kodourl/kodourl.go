@@ -8,10 +8,10 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"net/url"
 	"os"
 
 	"github.com/qiniu/x/token/protected"
+	"github.com/xushiwei/kodofs/kodoutil"
 )
 
 func init() {
@@ -20,7 +20,21 @@ func init() {
 }
 
 var (
-	help = flag.Bool("h", false, "show this help information")
+	help      = flag.Bool("h", false, "show this help information")
+	host      = flag.String("host", "", "download host of the bucket")
+	root      = flag.String("root", "", "key prefix that the file system is rooted at")
+	useHTTPS  = flag.Bool("https", false, "use https to access kodo services")
+	region    = flag.String("region", "", "id of the region that the bucket is located in, eg. z0")
+	cache     = flag.String("cache", "", "local directory to cache the file system")
+	cacheFile = flag.Bool("cacheFile", false, "cache file contents in the local directory")
+	offline   = flag.Bool("offline", false, "use the local cache only")
+	dirTTL    = flag.Duration("dirTTL", 0, "how long cached directory listings are fresh")
+	fileTTL   = flag.Duration("fileTTL", 0, "how long cached files are fresh")
+	stale     = flag.Bool("stale", false, "serve expired listings and files, and refresh them in background")
+	maxBytes  = flag.Int64("maxBytes", 0, "max total size of cached files")
+	maxFiles  = flag.Int("maxFiles", 0, "max number of cached files")
+	evict     = flag.String("evict", "", "policy to evict cached files: lru or lfu")
+	snapshot  = flag.String("snapshot", "", "tar snapshot to seed a new cache directory from")
 )
 
 func main() {
@@ -34,13 +48,29 @@ func main() {
 		flag.Usage()
 		return
 	}
-	params := make(url.Values)
-	params.Set("ak", args[1])
-	params.Set("sk", args[2])
-	query, err := protected.Encode(params)
+	u := &kodoutil.URL{
+		Bucket:    args[0],
+		AccessKey: args[1],
+		SecretKey: args[2],
+		Host:      *host,
+		Root:      *root,
+		UseHTTPS:  *useHTTPS,
+		Region:    *region,
+		Cache:     *cache,
+		CacheFile: *cacheFile,
+		Offline:   *offline,
+		DirTTL:    *dirTTL,
+		FileTTL:   *fileTTL,
+		Stale:     *stale,
+		MaxBytes:  *maxBytes,
+		MaxFiles:  *maxFiles,
+		Evict:     *evict,
+		Snapshot:  *snapshot,
+	}
+	url, err := u.Encode()
 	check(err)
 
-	fmt.Printf("kodo:%s?%s\n", args[0], query)
+	fmt.Println(url)
 }
 
 func check(err error) {
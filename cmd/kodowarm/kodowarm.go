@@ -0,0 +1,95 @@
+// kodowarm prefetches directory listings (and optionally file contents) of a bucket prefix
+// into a local cache directory of cached.FS, so that edge caches are warm before traffic
+// arrives.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/xushiwei/kodofs"
+	"github.com/xushiwei/kodofs/cached"
+	"github.com/xushiwei/kodofs/kodo"
+)
+
+var (
+	help        = flag.Bool("h", false, "show this help information")
+	accessKey   = flag.String("ak", "", "access key (default: $QINIU_ACCESS_KEY)")
+	secretKey   = flag.String("sk", "", "secret key (default: $QINIU_SECRET_KEY)")
+	host        = flag.String("host", "", "download host of the bucket (default: read files by kodo apis)")
+	root        = flag.String("root", "", "key prefix that the file system is rooted at")
+	useHTTPS    = flag.Bool("https", false, "use https to access kodo services")
+	region      = flag.String("region", "", "id of the region that the bucket is located in, eg. z0")
+	cache       = flag.String("cache", "", "local directory to cache the file system (required)")
+	files       = flag.Bool("files", false, "prefetch file contents, not only directory listings")
+	concurrency = flag.Int("c", 4, "max number of concurrent downloads")
+	budget      = flag.Int64("budget", 0, "byte budget of prefetching file contents (0: unlimited)")
+	verbose     = flag.Bool("v", false, "print each prefetched directory and file")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "kodowarm [flags] <bucket> [<dir>]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if *help || len(args) < 1 || len(args) > 2 || *cache == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "/"
+	if len(args) == 2 {
+		dir = args[1]
+	}
+	ak, sk := *accessKey, *secretKey
+	if ak == "" {
+		ak = os.Getenv("QINIU_ACCESS_KEY")
+	}
+	if sk == "" {
+		sk = os.Getenv("QINIU_SECRET_KEY")
+	}
+	if ak == "" || sk == "" {
+		log.Fatalln("kodowarm: access key and secret key are required")
+	}
+
+	opts := &kodofs.Options{
+		Options: kodo.Options{Region: *region, UseHTTPS: *useHTTPS},
+	}
+	bkt := kodofs.NewCredentials(ak, sk).NewBucketEx(args[0], *host, nil, opts)
+	if *root != "" {
+		bkt = bkt.Sub(*root)
+	}
+	fsys, err := cached.New(*cache, bkt, &cached.Options{CacheFile: *files})
+	check(err)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	progress, err := fsys.Warm(ctx, dir, &cached.WarmOptions{
+		Files:       *files,
+		Concurrency: *concurrency,
+		MaxBytes:    *budget,
+		Progress: func(p *cached.WarmProgress) {
+			if p.Err != nil {
+				log.Println("kodowarm:", p.Name, p.Err)
+			} else if *verbose {
+				log.Println("kodowarm:", p.Name)
+			}
+		},
+	})
+	if progress != nil {
+		fmt.Printf("dirs: %d, files: %d, bytes: %d, skipped: %d, errors: %d\n",
+			progress.Dirs, progress.Files, progress.Bytes, progress.Skipped, progress.Errors)
+	}
+	check(err)
+}
+
+func check(err error) {
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
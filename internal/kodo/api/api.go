@@ -26,6 +26,7 @@ func BytesFromRequest(r *http.Request) (b []byte, err error) {
 type QError struct {
 	Code    string
 	Message string
+	Cause   error // 导致该错误的原始错误（如带有 reqid 的服务端错误），可能为 nil
 }
 
 // Error 继承error接口
@@ -33,6 +34,11 @@ func (e *QError) Error() string {
 	return e.Code + ": " + e.Message
 }
 
+// Unwrap 返回原始错误，以便通过 errors.As 取得服务端错误的详细信息
+func (e *QError) Unwrap() error {
+	return e.Cause
+}
+
 // NewError 返回QError指针
 func NewError(code, message string) *QError {
 	return &QError{
@@ -41,4 +47,13 @@ func NewError(code, message string) *QError {
 	}
 }
 
+// WrapError 返回包装了原始错误 cause 的QError指针
+func WrapError(code string, cause error) *QError {
+	return &QError{
+		Code:    code,
+		Message: cause.Error(),
+		Cause:   cause,
+	}
+}
+
 // -----------------------------------------------------------------------------------------
@@ -9,6 +9,8 @@ import (
 
 	"github.com/xushiwei/kodofs/internal/kodo/auth"
 	clientv1 "github.com/xushiwei/kodofs/internal/kodo/client"
+	"github.com/xushiwei/kodofs/internal/kodo/clientv2"
+	"github.com/xushiwei/kodofs/internal/kodo/hostprovider"
 )
 
 // 资源管理相关的默认域名
@@ -22,9 +24,15 @@ const (
 // -----------------------------------------------------------------------------------------
 
 type BucketManagerOptions struct {
-	RetryMax int // 单域名重试次数，当前只有 uc 相关的服务有多域名
-	// 主备域名冻结时间（默认：600s），当一个域名请求失败（单个域名会被重试 TryTimes 次），会被冻结一段时间，使用备用域名进行重试，在冻结时间内，域名不能被使用，当一个操作中所有域名竣备冻结操作不在进行重试，返回最后一次操作的错误。
+	RetryMax int // 单域名重试次数（默认：2），rs、rsf、api、io 及 uc 服务均有多域名
+	// 主备域名冻结时间（默认：30s），当一个域名请求失败（单个域名会被重试 RetryMax 次），会被冻结一段时间，使用备用域名进行重试，在冻结时间内，域名不能被使用，当一个操作中所有域名竣备冻结操作不在进行重试，返回最后一次操作的错误。
 	HostFreezeDuration time.Duration
+	// 重试退避策略，为 nil 时使用 clientv2.DefaultBackoff()
+	Backoff *clientv2.Backoff
+	// 按域名熔断，为 nil 时不熔断；可被多个 BucketManager 共用
+	CircuitBreaker *clientv2.CircuitBreaker
+	// 对冲请求的延迟，GET 及列举请求经过该时间仍未返回时向另一个域名再发送一次请求，为 0 时不对冲
+	HedgeDelay time.Duration
 }
 
 // BucketManager 提供了对资源进行管理的操作
@@ -32,22 +40,54 @@ type BucketManager struct {
 	Client *clientv1.Client
 	Mac    *auth.Credentials
 	Cfg    *Config
-	// options BucketManagerOptions
+
+	options       BucketManagerOptions
+	hostProviders *hostprovider.Cache // 在多次请求间保留域名的冻结状态
 }
 
 // NewBucketManager 用来构建一个新的资源管理对象
 func NewBucketManager(mac *auth.Credentials, cfg *Config) *BucketManager {
+	return NewBucketManagerEx(mac, cfg, nil)
+}
+
+// NewBucketManagerEx 用于创建一个使用指定 client 的 BucketManager，clt 为 nil 时使用 clientv1.DefaultClient
+func NewBucketManagerEx(mac *auth.Credentials, cfg *Config, clt *clientv1.Client) *BucketManager {
+	return NewBucketManagerExWithOptions(mac, cfg, clt, BucketManagerOptions{})
+}
+
+// NewBucketManagerExWithOptions 用于创建一个使用指定 client 及重试参数的 BucketManager
+func NewBucketManagerExWithOptions(mac *auth.Credentials, cfg *Config, clt *clientv1.Client, options BucketManagerOptions) *BucketManager {
 	if cfg == nil {
 		cfg = &Config{}
 	}
 	if cfg.CentralRsHost == "" {
 		cfg.CentralRsHost = DefaultRsHost
 	}
+	if clt == nil {
+		clt = &clientv1.DefaultClient
+	}
+	if options.RetryMax <= 0 {
+		options.RetryMax = defaultRetryMax
+	}
+	if options.HostFreezeDuration <= 0 {
+		options.HostFreezeDuration = defaultHostFreezeDuration
+	}
+
+	hostProviders := cfg.HostProviders
+	if hostProviders == nil {
+		var opts hostprovider.Options
+		if b := options.CircuitBreaker; b != nil {
+			opts.Available = b.Available // 跳过熔断中的域名
+		}
+		hostProviders = hostprovider.NewCache(opts)
+	}
 
 	return &BucketManager{
-		Client: &clientv1.DefaultClient,
-		Mac:    mac,
-		Cfg:    cfg,
+		Client:        clt,
+		Mac:           mac,
+		Cfg:           cfg,
+		options:       options,
+		hostProviders: hostProviders,
 	}
 }
 
@@ -73,16 +113,62 @@ func uriFetch(resURL, bucket, key string) string {
 
 // Fetch 根据提供的远程资源链接来抓取一个文件到空间并已指定文件名保存
 func (m *BucketManager) Fetch(resURL, bucket, key string) (fetchRet FetchRet, err error) {
-	reqHost, rErr := m.IoReqHost(bucket)
-	if rErr != nil {
-		err = rErr
+	reqHosts, err := m.IoReqHosts(bucket)
+	if err != nil {
 		return
 	}
-	reqURL := fmt.Sprintf("%s%s", reqHost, uriFetch(resURL, bucket, key))
-	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, &fetchRet, "POST", reqURL, nil)
+	err = m.callWithHosts(context.Background(), bucket, reqHosts, &fetchRet, "POST", uriFetch(resURL, bucket, key))
 	return
 }
 
+// FileInfo 文件基本信息
+type FileInfo struct {
+	Hash     string `json:"hash"`
+	Fsize    int64  `json:"fsize"`
+	PutTime  int64  `json:"putTime"`
+	MimeType string `json:"mimeType"`
+	Type     int    `json:"type"`
+}
+
+// URIStat 构建 stat 接口的请求命令
+func URIStat(bucket, key string) string {
+	return fmt.Sprintf("/stat/%s", EncodedEntry(bucket, key))
+}
+
+// Stat 用来获取一个文件的基本信息
+func (m *BucketManager) Stat(bucket, key string) (info FileInfo, err error) {
+	return m.StatWithContext(context.Background(), bucket, key)
+}
+
+// StatWithContext 用来获取一个文件的基本信息，接受的context可以用来取消操作
+func (m *BucketManager) StatWithContext(ctx context.Context, bucket, key string) (info FileInfo, err error) {
+	reqHosts, err := m.RsReqHosts(bucket)
+	if err != nil {
+		return
+	}
+	err = m.callWithHosts(ctx, bucket, reqHosts, &info, "POST", URIStat(bucket, key))
+	return
+}
+
+// URIDelete 构建 delete 接口的请求命令
+func URIDelete(bucket, key string) string {
+	return fmt.Sprintf("/delete/%s", EncodedEntry(bucket, key))
+}
+
+// Delete 用来删除空间中的一个文件
+func (m *BucketManager) Delete(bucket, key string) (err error) {
+	return m.DeleteWithContext(context.Background(), bucket, key)
+}
+
+// DeleteWithContext 用来删除空间中的一个文件，接受的context可以用来取消删除操作
+func (m *BucketManager) DeleteWithContext(ctx context.Context, bucket, key string) (err error) {
+	reqHosts, err := m.RsReqHosts(bucket)
+	if err != nil {
+		return
+	}
+	return m.callWithHosts(ctx, bucket, reqHosts, nil, "POST", URIDelete(bucket, key))
+}
+
 func (m *BucketManager) IoReqHost(bucket string) (reqHost string, err error) {
 	var reqErr error
 
@@ -111,6 +197,34 @@ func (m *BucketManager) IovipHost(bucket string) (iovipHost string, err error) {
 	return
 }
 
+func (m *BucketManager) RsReqHost(bucket string) (reqHost string, err error) {
+	var reqErr error
+
+	if m.Cfg.RsHost == "" {
+		reqHost, reqErr = m.RsHost(bucket)
+		if reqErr != nil {
+			err = reqErr
+			return
+		}
+	} else {
+		reqHost = m.Cfg.RsHost
+	}
+	if !strings.HasPrefix(reqHost, "http") {
+		reqHost = endpoint(m.Cfg.UseHTTPS, reqHost)
+	}
+	return
+}
+
+func (m *BucketManager) RsHost(bucket string) (rsHost string, err error) {
+	zone, err := m.Zone(bucket)
+	if err != nil {
+		return
+	}
+
+	rsHost = zone.GetRsHost(m.Cfg.UseHTTPS)
+	return
+}
+
 func (m *BucketManager) RsfReqHost(bucket string) (reqHost string, err error) {
 	var reqErr error
 
@@ -141,12 +255,19 @@ func (m *BucketManager) RsfHost(bucket string) (rsfHost string, err error) {
 
 func (m *BucketManager) Zone(bucket string) (z *Zone, err error) {
 
-	if m.Cfg.Zone != nil {
-		z = m.Cfg.Zone
+	if z = m.Cfg.GetRegion(); z != nil {
 		return
 	}
 
-	z, err = GetZone(m.Mac.AccessKey, bucket)
+	options := DefaultUCApiOptions()
+	options.Hosts = m.Cfg.UcHosts
+	options.Client = m.Client
+	options.Metrics = m.Cfg.Metrics
+	options.Tracer = m.Cfg.Tracer
+	options.Logger = m.Cfg.Logger
+	options.RegionCache = m.Cfg.RegionCache
+	options.RegionTTL = m.Cfg.RegionTTL
+	z, err = GetRegionWithOptions(m.Mac.AccessKey, bucket, options)
 	return
 }
 
@@ -0,0 +1,197 @@
+package kodo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xushiwei/kodofs/internal/kodo/auth"
+	clientv1 "github.com/xushiwei/kodofs/internal/kodo/client"
+	"github.com/xushiwei/kodofs/internal/kodo/clientv2"
+	"github.com/xushiwei/kodofs/internal/kodo/hostprovider"
+)
+
+const (
+	defaultRetryMax           = 2
+	defaultHostFreezeDuration = 30 * time.Second
+)
+
+// -----------------------------------------------------------------------------------------
+
+// RsReqHosts 返回 rs 服务的全部域名（带 scheme），指定了 Cfg.RsHost 时只返回该域名
+func (m *BucketManager) RsReqHosts(bucket string) ([]string, error) {
+	return m.reqHosts(bucket, m.Cfg.RsHost, func(r *Region) (string, []string) {
+		return r.RsHost, r.RsHosts
+	})
+}
+
+// RsfReqHosts 返回 rsf 服务的全部域名（带 scheme），指定了 Cfg.RsfHost 时只返回该域名
+func (m *BucketManager) RsfReqHosts(bucket string) ([]string, error) {
+	return m.reqHosts(bucket, m.Cfg.RsfHost, func(r *Region) (string, []string) {
+		return r.RsfHost, r.RsfHosts
+	})
+}
+
+// ApiReqHosts 返回 api 服务的全部域名（带 scheme），指定了 Cfg.ApiHost 时只返回该域名
+func (m *BucketManager) ApiReqHosts(bucket string) ([]string, error) {
+	return m.reqHosts(bucket, m.Cfg.ApiHost, func(r *Region) (string, []string) {
+		return r.ApiHost, r.ApiHosts
+	})
+}
+
+// IoReqHosts 返回 io 服务的全部域名（带 scheme），指定了 Cfg.IoHost 时只返回该域名
+func (m *BucketManager) IoReqHosts(bucket string) ([]string, error) {
+	return m.reqHosts(bucket, m.Cfg.IoHost, func(r *Region) (string, []string) {
+		return r.IovipHost, r.IovipHosts
+	})
+}
+
+func (m *BucketManager) reqHosts(bucket, custom string, get func(r *Region) (string, []string)) ([]string, error) {
+	if custom != "" {
+		return []string{hostAddSchemeIfNeeded(m.Cfg.UseHTTPS, custom)}, nil
+	}
+	zone, err := m.Zone(bucket)
+	if err != nil {
+		return nil, err
+	}
+	host, hosts := get(zone)
+	if host != "" {
+		hosts = append([]string{host}, hosts...)
+	}
+	ret := make([]string, 0, len(hosts))
+	for _, host := range removeRepeatStringItem(hosts) {
+		ret = append(ret, hostAddSchemeIfNeeded(m.Cfg.UseHTTPS, host))
+	}
+	if len(ret) == 0 {
+		return nil, errors.New("no hosts of the service in the region of bucket " + bucket)
+	}
+	return ret, nil
+}
+
+// hostProvider 返回域名列表 hosts（带 scheme）的 HostProvider，同一服务的多次请求共用，以保留域名的冻结状态
+func (m *BucketManager) hostProvider(hosts []string) hostprovider.HostProvider {
+	return m.hostProviders.Get(hosts)
+}
+
+// FrozenHosts 返回冻结中的域名及其解冻时间，用于调试
+func (m *BucketManager) FrozenHosts() []hostprovider.FrozenHost {
+	return m.hostProviders.Frozen()
+}
+
+// apiClient 返回请求 hosts 的 client：单域名重试 options.RetryMax 次，失败后冻结该域名并切换到备用域名
+func (m *BucketManager) apiClient(hosts []string, is ...clientv2.Interceptor) (clientv2.Client, string) {
+	if b := m.options.CircuitBreaker; b != nil {
+		is = append(is, b.Interceptor())
+	}
+	is = append(is, m.Cfg.interceptors()...)
+	hp := m.hostProvider(hosts)
+	if m.options.HedgeDelay > 0 {
+		is = append(is, clientv2.NewHedgeInterceptor(clientv2.HedgeConfig{
+			Delay:        m.options.HedgeDelay,
+			HostProvider: hp,
+		}))
+	}
+	reqHost := hosts[0]
+	if host, err := hp.Provider(); err == nil { // 所有域名都被冻结时仍使用主域名
+		reqHost = host
+	}
+	is = append(is,
+		clientv2.NewHostsRetryInterceptor(clientv2.HostsRetryConfig{
+			RetryConfig: clientv2.RetryConfig{
+				RetryMax: len(hosts) - 1,
+				Backoff:  m.options.Backoff,
+				Metrics:  m.Cfg.Metrics,
+			},
+			HostFreezeDuration: m.options.HostFreezeDuration,
+			HostProvider:       hp,
+		}),
+		clientv2.NewSimpleRetryInterceptor(clientv2.RetryConfig{
+			RetryMax: m.options.RetryMax,
+			Backoff:  m.options.Backoff,
+			Metrics:  m.Cfg.Metrics,
+		}),
+	)
+	return clientv2.NewClient(clientv2.NewClientWithClientV1(m.Client), is...), reqHost
+}
+
+// callWithHosts 以管理凭证请求 bucket 的服务域名 hosts 中的可用域名，请求失败时重试并切换域名；
+// ctx 未标记接口类别时视为 rs 类接口
+func (m *BucketManager) callWithHosts(ctx context.Context, bucket string, hosts []string, ret interface{}, method, path string) error {
+	if _, ok := clientv2.APIClassFromContext(ctx); !ok {
+		ctx = clientv2.WithAPIClass(ctx, clientv2.APIClassRs)
+	}
+	c, reqHost := m.apiClient(hosts, clientv2.NewAuthInterceptor(clientv2.AuthConfig{
+		Credentials: *m.Mac,
+		TokenType:   auth.TokenQiniu,
+	}))
+	resp, err := clientv2.Do(c, clientv2.RequestParams{
+		Context: ctx,
+		Method:  method,
+		Url:     reqHost + path,
+	})
+	if resp != nil {
+		defer func() {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	if err != nil {
+		m.Cfg.invalidateRegion(m.Mac.AccessKey, bucket, err)
+		return err
+	}
+	if ret == nil || resp.ContentLength == 0 {
+		return nil
+	}
+	return clientv1.DecodeJsonFromReader(resp.Body, ret)
+}
+
+// DownloadWithContext 通过私有下载链接（在 deadline 前有效）从 io 服务下载文件，请求失败时重试并切换域名。
+// 返回的 resp 状态码为 2xx，非 2xx 时返回 *clientv1.ErrorInfo
+func (m *BucketManager) DownloadWithContext(ctx context.Context, bucket, key string, header http.Header, deadline time.Time) (*http.Response, error) {
+	hosts, err := m.IoReqHosts(bucket)
+	if err != nil {
+		return nil, err
+	}
+	signer := clientv2.NewSimpleInterceptor(func(req *http.Request, handler clientv2.Handler) (*http.Response, error) {
+		req.URL = signDownloadURL(m.Mac, req.URL) // 切换域名后需要重新签名
+		return handler(req)
+	})
+	c, reqHost := m.apiClient(hosts, signer)
+	u := &url.URL{Path: "/" + key}
+	reqURL := reqHost + u.EscapedPath() + "?e=" + strconv.FormatInt(deadline.Unix(), 10)
+	if header == nil {
+		header = http.Header{}
+	}
+	resp, err := clientv2.Do(c, clientv2.RequestParams{
+		Context: clientv2.WithAPIClass(ctx, clientv2.APIClassIo),
+		Method:  clientv2.RequestMethodGet,
+		Url:     reqURL,
+		Header:  header,
+	})
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		m.Cfg.invalidateRegion(m.Mac.AccessKey, bucket, err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// signDownloadURL 为私有下载链接签名，替换已有的签名
+func signDownloadURL(mac *auth.Credentials, u *url.URL) *url.URL {
+	ret := *u
+	if i := strings.Index(ret.RawQuery, "&token="); i >= 0 {
+		ret.RawQuery = ret.RawQuery[:i]
+	}
+	s := ret.String()
+	ret.RawQuery += "&token=" + mac.Sign([]byte(s))
+	return &ret
+}
+
+// -----------------------------------------------------------------------------------------
@@ -7,7 +7,7 @@ import (
 	"net/url"
 	"strconv"
 
-	"github.com/xushiwei/kodofs/internal/kodo/auth"
+	"github.com/xushiwei/kodofs/internal/kodo/clientv2"
 )
 
 // ListItem 为文件列举的返回值
@@ -158,15 +158,14 @@ func (m *BucketManager) ListFilesWithContext(ctx context.Context, bucket string,
 		return nil, false, errors.New("invalid list limit, only allow [1, 1000]")
 	}
 
-	ctx = auth.WithCredentialsType(ctx, m.Mac, auth.TokenQiniu)
-	host, reqErr := m.RsfReqHost(bucket)
+	hosts, reqErr := m.RsfReqHosts(bucket)
 	if reqErr != nil {
 		return nil, false, reqErr
 	}
 
 	ret = &ListFilesRet{}
-	reqURL := fmt.Sprintf("%s%s", host, uriListFiles(bucket, inputOptions.prefix, inputOptions.delimiter, inputOptions.marker, inputOptions.limit))
-	err = m.Client.CredentialedCall(ctx, m.Mac, auth.TokenQiniu, ret, "POST", reqURL, nil)
+	reqPath := uriListFiles(bucket, inputOptions.prefix, inputOptions.delimiter, inputOptions.marker, inputOptions.limit)
+	err = m.callWithHosts(clientv2.WithAPIClass(ctx, clientv2.APIClassList), bucket, hosts, ret, "POST", reqPath)
 	if err != nil {
 		return nil, false, err
 	}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -107,6 +108,10 @@ type ErrorInfo struct {
 	Reqid string `json:"reqid,omitempty"`
 	Errno int    `json:"errno,omitempty"`
 	Code  int    `json:"code"`
+	XLog  string `json:"xlog,omitempty"` // 服务端返回的 X-Log，记录了请求经过的服务
+	Host  string `json:"host,omitempty"` // 返回错误的域名
+
+	RetryAfter time.Duration `json:"-"` // 服务端通过 Retry-After 指定的重试等待时间
 }
 
 func (r *ErrorInfo) ErrorDetail() string {
@@ -115,9 +120,13 @@ func (r *ErrorInfo) ErrorDetail() string {
 	return string(msg)
 }
 
+// Error 返回错误信息，带有 reqid 以便向七牛反馈问题
 func (r *ErrorInfo) Error() string {
 
-	return r.Err
+	if r.Reqid == "" {
+		return r.Err
+	}
+	return r.Err + " (reqid: " + r.Reqid + ")"
 }
 
 func (r *ErrorInfo) RpcError() (code, errno int, key, err string) {
@@ -155,8 +164,13 @@ func ResponseError(resp *http.Response) (err error) {
 
 	e := &ErrorInfo{
 		Reqid: resp.Header.Get("X-Reqid"),
+		XLog:  resp.Header.Get("X-Log"),
 		Code:  resp.StatusCode,
 	}
+	if resp.Request != nil && resp.Request.URL != nil {
+		e.Host = resp.Request.URL.Host
+	}
+	e.RetryAfter, _ = ParseRetryAfter(resp.Header.Get("Retry-After"))
 	if resp.StatusCode > 299 {
 		if resp.ContentLength != 0 {
 			ct, ok := resp.Header["Content-Type"]
@@ -174,6 +188,27 @@ func ResponseError(resp *http.Response) (err error) {
 	return e
 }
 
+// ParseRetryAfter 解析 Retry-After 头，其值为秒数或 HTTP 日期
+func ParseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
 func CallRet(ctx context.Context, ret interface{}, resp *http.Response) (err error) {
 
 	defer func() {
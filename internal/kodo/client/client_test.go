@@ -0,0 +1,60 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponseError(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: 573,
+		Header: http.Header{
+			"Content-Type": {"application/json"},
+			"X-Reqid":      {"server-reqid"},
+			"X-Log":        {"UP:3"},
+			"Retry-After":  {"2"},
+		},
+		ContentLength: -1,
+		Body:          io.NopCloser(strings.NewReader(`{"error":"too many requests","errno":1}`)),
+		Request:       &http.Request{URL: &url.URL{Scheme: "https", Host: "up.example.com"}},
+	}
+	e := ResponseError(resp).(*ErrorInfo)
+	if e.Code != 573 || e.Err != "too many requests" || e.Errno != 1 || e.Reqid != "server-reqid" ||
+		e.XLog != "UP:3" || e.Host != "up.example.com" || e.RetryAfter != 2*time.Second {
+		t.Fatal("ResponseError:", e.ErrorDetail())
+	}
+	if msg := e.Error(); msg != "too many requests (reqid: server-reqid)" {
+		t.Fatal("Error:", msg)
+	}
+
+	resp = &http.Response{StatusCode: 502, ContentLength: -1, Body: io.NopCloser(strings.NewReader("bad gateway\n"))}
+	if e = ResponseError(resp).(*ErrorInfo); e.Err != "bad gateway" || e.Reqid != "" || e.Error() != "bad gateway" {
+		t.Fatal("ResponseError:", e.ErrorDetail())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	for _, c := range []struct {
+		v  string
+		d  time.Duration
+		ok bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", 0, true},
+	} {
+		if d, ok := ParseRetryAfter(c.v); d != c.d || ok != c.ok {
+			t.Errorf("ParseRetryAfter(%q) = %v, %v", c.v, d, ok)
+		}
+	}
+	if d, ok := ParseRetryAfter(future); !ok || d < 59*time.Minute || d > time.Hour {
+		t.Fatal("ParseRetryAfter:", d, ok)
+	}
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// 七牛服务的错误码
+const (
+	CodeUnauthorized = 401 // 认证失败
+	CodeNotFound     = 612 // 文件不存在
+	CodeExists       = 614 // 文件已存在
+	CodeNoSuchBucket = 631 // 空间不存在
+	CodeRateLimited  = 573 // 请求过于频繁
+)
+
+// codeError 七牛错误码对应的哨兵错误，可与 io/fs 中的错误匹配
+type codeError struct {
+	msg   string
+	fsErr error
+}
+
+func (e *codeError) Error() string {
+	return e.msg
+}
+
+func (e *codeError) Is(target error) bool {
+	return e.fsErr != nil && target == e.fsErr
+}
+
+// 可通过 errors.Is 与 *ErrorInfo 匹配的哨兵错误
+var (
+	ErrNotFound           error = &codeError{"no such file or directory", fs.ErrNotExist}
+	ErrExists             error = &codeError{"file exists", fs.ErrExist}
+	ErrNoSuchBucket       error = &codeError{"no such bucket", fs.ErrNotExist}
+	ErrUnauthorized       error = &codeError{"unauthorized", fs.ErrPermission}
+	ErrRateLimited        error = &codeError{"rate limited", nil}
+	ErrArchiveNotRestored error = &codeError{"archived file not restored", nil}
+)
+
+// Is 使 errors.Is 可按错误码匹配哨兵错误（如 ErrNotFound）及对应的 io/fs 错误（如 fs.ErrNotExist）
+func (r *ErrorInfo) Is(target error) bool {
+	sentinel := r.sentinel()
+	return sentinel != nil && (sentinel == target || errors.Is(sentinel, target))
+}
+
+func (r *ErrorInfo) sentinel() error {
+	// 归档存储的文件未解冻时，服务端返回 4xx 错误，错误信息提示需要先解冻
+	if r.Code/100 == 4 && strings.Contains(strings.ToLower(r.Err), "restore") {
+		return ErrArchiveNotRestored
+	}
+	switch r.Code {
+	case CodeNotFound, http.StatusNotFound:
+		return ErrNotFound
+	case CodeExists:
+		return ErrExists
+	case CodeNoSuchBucket:
+		return ErrNoSuchBucket
+	case CodeUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case CodeRateLimited, http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package clientv2
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	clientv1 "github.com/xushiwei/kodofs/internal/kodo/client"
+)
+
+const (
+	defaultBackoffBase        = 50 * time.Millisecond
+	defaultBackoffMax         = 5 * time.Second
+	defaultRateLimitedBackoff = time.Second
+	defaultMaxRetryAfter      = 30 * time.Second
+)
+
+// Backoff 重试退避策略：指数退避 + 全抖动（full jitter）
+// 第 n 次重试（从 0 开始）前等待 [0, min(Max, Base * 2^n)) 中的随机时间
+type Backoff struct {
+	Base time.Duration // 初始退避时间（默认：50ms）
+	Max  time.Duration // 单次退避时间上限（默认：5s）
+
+	// 一次请求所有重试的总等待时间预算，超过预算则不再重试；为 0 时不限制
+	Budget time.Duration
+
+	// 服务端限流（573/429）时的最小等待时间（默认：1s），服务端返回 Retry-After 时以其为准
+	RateLimited time.Duration
+
+	// 服务端 Retry-After 指定的等待时间上限（默认：30s），超过上限时只等待上限时间后重试，
+	// 避免服务端指定过长的等待使调用方长时间阻塞
+	MaxRetryAfter time.Duration
+}
+
+// DefaultBackoff 返回默认的退避策略
+func DefaultBackoff() *Backoff {
+	return &Backoff{}
+}
+
+// Delay 返回第 attempt 次重试（从 0 开始）前的等待时间，不考虑 Retry-After
+func (b *Backoff) Delay(attempt int) time.Duration {
+	base, max := b.Base, b.Max
+	if base <= 0 {
+		base = defaultBackoffBase
+	}
+	if max <= 0 {
+		max = defaultBackoffMax
+	}
+	d := max
+	if attempt < 32 {
+		if v := base << uint(attempt); v > 0 && v < max {
+			d = v
+		}
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// NewWaiter 返回一次请求的重试等待器，b 为 nil 时使用 DefaultBackoff()
+func (b *Backoff) NewWaiter() *BackoffWaiter {
+	if b == nil {
+		b = DefaultBackoff()
+	}
+	return &BackoffWaiter{policy: b}
+}
+
+func (c *RetryConfig) newWaiter() *BackoffWaiter {
+	return &BackoffWaiter{policy: c.Backoff, interval: c.RetryInterval}
+}
+
+// BackoffWaiter 为一次请求的多次重试计算等待时间并等待
+type BackoffWaiter struct {
+	policy   *Backoff
+	interval func() time.Duration // policy 为 nil 时使用，兼容 RetryConfig.RetryInterval
+	attempt  int
+	waited   time.Duration
+}
+
+// next 返回下次重试前的等待时间，超过总预算时 ok 为 false
+func (w *BackoffWaiter) next(resp *http.Response, err error) (d time.Duration, ok bool) {
+	b := w.policy
+	if b == nil {
+		d = w.interval()
+		if ra, has := RetryAfter(resp, err); has {
+			d = clampRetryAfter(ra, 0)
+		}
+		return d, true
+	}
+	d = b.Delay(w.attempt)
+	if IsRateLimited(resp, err) {
+		min := b.RateLimited
+		if min <= 0 {
+			min = defaultRateLimitedBackoff
+		}
+		if d < min {
+			d = min
+		}
+	}
+	if ra, has := RetryAfter(resp, err); has {
+		d = clampRetryAfter(ra, b.MaxRetryAfter)
+	}
+	if b.Budget > 0 && w.waited+d > b.Budget {
+		return 0, false
+	}
+	w.attempt++
+	w.waited += d
+	return d, true
+}
+
+// clampRetryAfter 将 Retry-After 的等待时间限制在 max 以内，max 为 0 时使用默认上限
+func clampRetryAfter(d, max time.Duration) time.Duration {
+	if max <= 0 {
+		max = defaultMaxRetryAfter
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// Wait 在重试前等待，并关闭将被丢弃的 resp；超过总预算时 ok 为 false，ctx 被取消时返回其错误
+func (w *BackoffWaiter) Wait(ctx context.Context, resp *http.Response, err error) (ok bool, sErr error) {
+	d, ok := w.next(resp, err)
+	if !ok {
+		return
+	}
+	closeResponse(resp)
+	if d >= time.Microsecond {
+		sErr = Sleep(ctx, d)
+	}
+	return
+}
+
+func closeResponse(resp *http.Response) {
+	if resp != nil && resp.Body != nil {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096)) // 尽量复用连接
+		resp.Body.Close()
+	}
+}
+
+// Sleep 等待 d 时间，ctx 被取消时提前返回 ctx.Err()
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IsRateLimited 判断请求是否被服务端限流（573 或 429）
+func IsRateLimited(resp *http.Response, err error) bool {
+	if resp != nil {
+		return isStatusCodeRateLimited(resp.StatusCode)
+	}
+	if e, ok := err.(*clientv1.ErrorInfo); ok {
+		return isStatusCodeRateLimited(e.Code)
+	}
+	return false
+}
+
+func isStatusCodeRateLimited(statusCode int) bool {
+	return statusCode == 573 || statusCode == http.StatusTooManyRequests
+}
+
+// RetryAfter 返回服务端通过 Retry-After 指定的等待时间
+func RetryAfter(resp *http.Response, err error) (time.Duration, bool) {
+	if resp != nil {
+		return clientv1.ParseRetryAfter(resp.Header.Get("Retry-After"))
+	}
+	if e, ok := err.(*clientv1.ErrorInfo); ok && e.RetryAfter > 0 {
+		return e.RetryAfter, true
+	}
+	return 0, false
+}
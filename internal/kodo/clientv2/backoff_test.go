@@ -0,0 +1,160 @@
+package clientv2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	clientv1 "github.com/xushiwei/kodofs/internal/kodo/client"
+)
+
+// stubHandler 依次以 codes 响应请求（0 表示返回 io.EOF 错误），之后的请求都返回 200，并记录请求的 host
+type stubHandler struct {
+	codes  []int
+	header http.Header
+	delay  func(host string) time.Duration // 可选，按 host 延迟响应，直到请求被取消
+	hosts  []string
+	mutex  sync.Mutex
+}
+
+func (p *stubHandler) handle(req *http.Request) (*http.Response, error) {
+	p.mutex.Lock()
+	code := http.StatusOK
+	if n := len(p.hosts); n < len(p.codes) {
+		code = p.codes[n]
+	}
+	p.hosts = append(p.hosts, req.URL.Host)
+	p.mutex.Unlock()
+
+	if p.delay != nil {
+		if err := Sleep(req.Context(), p.delay(req.URL.Host)); err != nil {
+			return nil, err
+		}
+	}
+	if code == 0 {
+		return nil, io.EOF
+	}
+	return &http.Response{StatusCode: code, Header: p.header.Clone(), Body: http.NoBody, Request: req}, nil
+}
+
+func (p *stubHandler) requests() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]string(nil), p.hosts...)
+}
+
+func newRequest(t *testing.T, rawurl string) *http.Request {
+	req, err := http.NewRequest(http.MethodGet, rawurl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := &Backoff{Base: 10 * time.Millisecond, Max: 100 * time.Millisecond}
+	for attempt, max := range []time.Duration{10, 20, 40, 80, 100, 100} {
+		max *= time.Millisecond
+		for i := 0; i < 100; i++ {
+			if d := b.Delay(attempt); d <= 0 || d > max {
+				t.Fatalf("Delay(%d): %v > %v", attempt, d, max)
+			}
+		}
+	}
+	if d := b.Delay(100); d <= 0 || d > b.Max {
+		t.Fatal("Delay(100):", d)
+	}
+}
+
+func TestBackoffWaiter(t *testing.T) {
+	rateLimited := &http.Response{StatusCode: 573, Header: http.Header{}}
+	retryAfter := &http.Response{StatusCode: 503, Header: http.Header{"Retry-After": {"3"}}}
+	retryAfterHour := &http.Response{StatusCode: 573, Header: http.Header{"Retry-After": {"3600"}}}
+	cases := []struct {
+		name     string
+		b        *Backoff
+		resp     *http.Response
+		err      error
+		min, max time.Duration
+		ok       bool
+	}{
+		{"jitter", &Backoff{Base: time.Millisecond}, nil, io.EOF, 0, time.Millisecond, true},
+		{"rate-limited", &Backoff{Base: time.Millisecond}, rateLimited, nil, time.Second, time.Second, true},
+		{"rate-limited-min", &Backoff{Base: time.Millisecond, RateLimited: 5 * time.Millisecond}, rateLimited, nil, 5 * time.Millisecond, 5 * time.Millisecond, true},
+		{"rate-limited-error", &Backoff{Base: time.Millisecond}, nil, &clientv1.ErrorInfo{Code: 429, RetryAfter: 2 * time.Second}, 2 * time.Second, 2 * time.Second, true},
+		{"retry-after", &Backoff{Base: time.Millisecond}, retryAfter, nil, 3 * time.Second, 3 * time.Second, true},
+		{"retry-after-default-max", &Backoff{Base: time.Millisecond}, retryAfterHour, nil, 30 * time.Second, 30 * time.Second, true},
+		{"retry-after-max", &Backoff{Base: time.Millisecond, MaxRetryAfter: 2 * time.Second}, retryAfterHour, nil, 2 * time.Second, 2 * time.Second, true},
+		{"over-budget", &Backoff{Base: time.Millisecond, Budget: 2 * time.Second}, retryAfter, nil, 0, 0, false},
+	}
+	for _, c := range cases {
+		d, ok := c.b.NewWaiter().next(c.resp, c.err)
+		if ok != c.ok || d < c.min || d > c.max {
+			t.Errorf("%s: next = %v, %v", c.name, d, ok)
+		}
+	}
+
+	// 总预算包括之前的等待
+	w := (&Backoff{Base: time.Millisecond, RateLimited: 40 * time.Millisecond, Budget: 100 * time.Millisecond}).NewWaiter()
+	for i, want := range []bool{true, true, false} {
+		if _, ok := w.next(rateLimited, nil); ok != want {
+			t.Fatalf("next %d: %v", i, ok)
+		}
+	}
+}
+
+func TestWaiterCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := (&Backoff{RateLimited: time.Minute}).NewWaiter()
+	if ok, err := w.Wait(ctx, &http.Response{StatusCode: 573, Body: http.NoBody}, nil); !ok || err != context.Canceled {
+		t.Fatal("Wait:", ok, err)
+	}
+}
+
+func TestSimpleRetry(t *testing.T) {
+	backoff := &Backoff{Base: time.Millisecond, RateLimited: time.Millisecond}
+	cases := []struct {
+		name     string
+		codes    []int
+		retryMax int
+		code     int
+		requests int
+	}{
+		{"ok", nil, 3, 200, 1},
+		{"retried", []int{503, 0, 573}, 3, 200, 4},
+		{"retry-max", []int{502, 502, 502}, 1, 502, 2},
+		{"not-retryable", []int{404}, 3, 404, 1},
+		{"not-retryable-5xx", []int{631}, 3, 631, 1},
+		{"no-retry", []int{502}, 0, 502, 1},
+	}
+	for _, c := range cases {
+		h := &stubHandler{codes: c.codes}
+		interceptor := NewSimpleRetryInterceptor(RetryConfig{RetryMax: c.retryMax, Backoff: backoff})
+		resp, err := interceptor.Intercept(newRequest(t, "http://rs.example.com/stat"), h.handle)
+		if err != nil || resp.StatusCode != c.code || len(h.requests()) != c.requests {
+			t.Errorf("%s: %v %v, %d requests", c.name, resp, err, len(h.requests()))
+		}
+	}
+}
+
+func TestRateLimitedErrors(t *testing.T) {
+	for _, c := range []struct {
+		resp *http.Response
+		err  error
+		want bool
+	}{
+		{&http.Response{StatusCode: 573}, nil, true},
+		{&http.Response{StatusCode: 429}, nil, true},
+		{&http.Response{StatusCode: 503}, nil, false},
+		{nil, &clientv1.ErrorInfo{Code: 573}, true},
+		{nil, io.EOF, false},
+	} {
+		if got := IsRateLimited(c.resp, c.err); got != c.want {
+			t.Errorf("IsRateLimited(%v, %v) = %v", c.resp, c.err, got)
+		}
+	}
+}
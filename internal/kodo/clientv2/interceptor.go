@@ -5,13 +5,19 @@ import (
 )
 
 const (
-	InterceptorPriorityDefault     InterceptorPriority = 100
-	InterceptorPriorityRetryHosts  InterceptorPriority = 200
-	InterceptorPriorityRetrySimple InterceptorPriority = 300
-	InterceptorPrioritySetHeader   InterceptorPriority = 400
-	InterceptorPriorityNormal      InterceptorPriority = 500
-	InterceptorPriorityAuth        InterceptorPriority = 600
-	InterceptorPriorityDebug       InterceptorPriority = 700
+	InterceptorPriorityDefault        InterceptorPriority = 100
+	InterceptorPriorityHedge          InterceptorPriority = 150
+	InterceptorPriorityRetryHosts     InterceptorPriority = 200
+	InterceptorPriorityCircuitBreaker InterceptorPriority = 250
+	InterceptorPriorityRetrySimple    InterceptorPriority = 300
+	InterceptorPriorityRateLimit      InterceptorPriority = 350
+	InterceptorPriorityMetrics        InterceptorPriority = 380
+	InterceptorPriorityTrace          InterceptorPriority = 390
+	InterceptorPrioritySetHeader      InterceptorPriority = 400
+	InterceptorPriorityNormal         InterceptorPriority = 500
+	InterceptorPriorityAuth           InterceptorPriority = 600
+	InterceptorPriorityLog            InterceptorPriority = 650
+	InterceptorPriorityDebug          InterceptorPriority = 700
 )
 
 type InterceptorPriority int
@@ -0,0 +1,242 @@
+package clientv2
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 域名熔断中，请求未发出
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState 熔断器状态
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // 正常放行请求
+	CircuitOpen                         // 熔断，拒绝请求
+	CircuitHalfOpen                     // 冷却结束，放行少量试探请求
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+type CircuitBreakerConfig struct {
+	Window           time.Duration // 统计失败率的时间窗口（默认：10s）
+	MinRequests      int           // 窗口内请求数达到该值后才会熔断（默认：10）
+	FailureRatio     float64       // 窗口内失败率达到该值时熔断（默认：0.5）
+	CoolDown         time.Duration // 熔断后经过该时间进入半开状态（默认：30s）
+	HalfOpenRequests int           // 半开状态下放行的试探请求数，全部成功后恢复正常（默认：1）
+
+	// 判断请求是否失败，默认网络错误及 5xx（限流除外）为失败
+	ShouldFail func(req *http.Request, resp *http.Response, err error) bool
+
+	// 熔断器状态变化时被调用，可用于告警；不要在其中执行耗时操作
+	OnStateChange func(host string, from, to CircuitState)
+}
+
+func (c *CircuitBreakerConfig) init() {
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 10
+	}
+	if c.FailureRatio <= 0 || c.FailureRatio > 1 {
+		c.FailureRatio = 0.5
+	}
+	if c.CoolDown <= 0 {
+		c.CoolDown = 30 * time.Second
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = 1
+	}
+	if c.ShouldFail == nil {
+		c.ShouldFail = func(req *http.Request, resp *http.Response, err error) bool {
+			if IsRateLimited(resp, err) {
+				return false // 限流不是域名故障
+			}
+			return isResponseRetryable(resp) || IsErrorRetryable(err)
+		}
+	}
+}
+
+type hostCircuit struct {
+	state       CircuitState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int // 半开状态下已放行的试探请求数
+	successes   int // 半开状态下成功的试探请求数
+}
+
+// CircuitBreaker 按域名熔断：窗口内失败率过高时熔断该域名，冷却后放行试探请求，成功则恢复。
+// 一个 CircuitBreaker 可被多个 client 共用
+type CircuitBreaker struct {
+	config CircuitBreakerConfig
+	now    func() time.Time // 统计窗口及冷却的计时时钟，测试中可替换
+	hosts  map[string]*hostCircuit
+	mutex  sync.Mutex
+}
+
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	config.init()
+	return &CircuitBreaker{
+		config: config,
+		now:    time.Now,
+		hosts:  make(map[string]*hostCircuit),
+	}
+}
+
+// coolDown 判断熔断的域名是否已冷却
+func (b *CircuitBreaker) coolDown(c *hostCircuit) bool {
+	return b.now().Sub(c.openedAt) >= b.config.CoolDown
+}
+
+// State 返回域名（不带 scheme）的熔断器状态
+func (b *CircuitBreaker) State(host string) CircuitState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if c, ok := b.hosts[host]; ok {
+		if c.state == CircuitOpen && b.coolDown(c) {
+			return CircuitHalfOpen
+		}
+		return c.state
+	}
+	return CircuitClosed
+}
+
+// Available 判断域名当前是否可以放行请求，不改变熔断器状态，可用作 hostprovider 的过滤条件
+func (b *CircuitBreaker) Available(host string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	c, ok := b.hosts[host]
+	if !ok {
+		return true
+	}
+	switch c.state {
+	case CircuitOpen:
+		return b.coolDown(c)
+	case CircuitHalfOpen:
+		return c.probes < b.config.HalfOpenRequests
+	}
+	return true
+}
+
+// allow 请求发出前调用，返回是否放行
+func (b *CircuitBreaker) allow(host string) (ok bool) {
+	var from CircuitState
+	b.mutex.Lock()
+	c := b.hosts[host]
+	if c == nil {
+		c = &hostCircuit{windowStart: b.now()}
+		b.hosts[host] = c
+	}
+	if c.state == CircuitOpen && b.coolDown(c) {
+		from = b.setState(c, CircuitHalfOpen)
+		defer b.notify(host, from, CircuitHalfOpen)
+	}
+	switch c.state {
+	case CircuitOpen:
+		ok = false
+	case CircuitHalfOpen:
+		if ok = c.probes < b.config.HalfOpenRequests; ok {
+			c.probes++
+		}
+	default:
+		ok = true
+	}
+	b.mutex.Unlock()
+	return
+}
+
+// done 请求结束后调用，记录请求结果
+func (b *CircuitBreaker) done(host string, failed bool) {
+	b.mutex.Lock()
+	c := b.hosts[host]
+	to := c.state
+	switch c.state {
+	case CircuitHalfOpen:
+		if failed {
+			to = CircuitOpen
+		} else if c.successes++; c.successes >= b.config.HalfOpenRequests {
+			to = CircuitClosed
+		}
+	case CircuitClosed:
+		now := b.now()
+		if now.Sub(c.windowStart) > b.config.Window {
+			c.windowStart, c.requests, c.failures = now, 0, 0
+		}
+		c.requests++
+		if failed {
+			c.failures++
+		}
+		if c.requests >= b.config.MinRequests && float64(c.failures) >= b.config.FailureRatio*float64(c.requests) {
+			to = CircuitOpen
+		}
+	}
+	if to == c.state {
+		b.mutex.Unlock()
+		return
+	}
+	from := b.setState(c, to)
+	b.mutex.Unlock()
+	b.notify(host, from, to)
+}
+
+func (b *CircuitBreaker) setState(c *hostCircuit, to CircuitState) (from CircuitState) {
+	from, c.state = c.state, to
+	c.probes, c.successes = 0, 0
+	switch to {
+	case CircuitOpen:
+		c.openedAt = b.now()
+	case CircuitClosed:
+		c.windowStart, c.requests, c.failures = b.now(), 0, 0
+	}
+	return
+}
+
+func (b *CircuitBreaker) notify(host string, from, to CircuitState) {
+	if b.config.OnStateChange != nil {
+		b.config.OnStateChange(host, from, to)
+	}
+}
+
+// Interceptor 返回熔断拦截器，其位于主备域名重试与单域名重试之间：
+// 一个域名上的一次请求（含其单域名重试）记录一次结果，域名熔断时返回 ErrCircuitOpen，由主备域名重试切换域名
+func (b *CircuitBreaker) Interceptor() Interceptor {
+	return &circuitBreakerInterceptor{b}
+}
+
+type circuitBreakerInterceptor struct {
+	breaker *CircuitBreaker
+}
+
+func (interceptor *circuitBreakerInterceptor) Priority() InterceptorPriority {
+	return InterceptorPriorityCircuitBreaker
+}
+
+func (interceptor *circuitBreakerInterceptor) Intercept(req *http.Request, handler Handler) (*http.Response, error) {
+	if interceptor == nil || req == nil {
+		return handler(req)
+	}
+
+	b, host := interceptor.breaker, req.URL.Host
+	if !b.allow(host) {
+		return nil, ErrCircuitOpen
+	}
+	resp, err := handler(req)
+	b.done(host, b.config.ShouldFail(req, resp, err))
+	return resp, err
+}
@@ -0,0 +1,117 @@
+package clientv2
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now   time.Time
+	mutex sync.Mutex
+}
+
+func (p *fakeClock) Now() time.Time {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.now
+}
+
+func (p *fakeClock) Add(d time.Duration) {
+	p.mutex.Lock()
+	p.now = p.now.Add(d)
+	p.mutex.Unlock()
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var events []string
+	var mutex sync.Mutex
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	b := NewCircuitBreaker(CircuitBreakerConfig{
+		MinRequests: 2,
+		CoolDown:    30 * time.Second,
+		OnStateChange: func(host string, from, to CircuitState) {
+			mutex.Lock()
+			events = append(events, host+":"+from.String()+">"+to.String())
+			mutex.Unlock()
+		},
+	})
+	b.now = clock.Now
+	interceptor := b.Interceptor()
+	h := &stubHandler{codes: []int{502, 0, 200, 502}}
+	do := func(host string) error {
+		_, err := interceptor.Intercept(newRequest(t, "http://"+host+"/stat"), h.handle)
+		return err
+	}
+
+	do("a")
+	if b.State("a") != CircuitClosed || !b.Available("a") {
+		t.Fatal("State: opened before MinRequests")
+	}
+	do("a")
+	if b.State("a") != CircuitOpen || b.Available("a") {
+		t.Fatal("State:", b.State("a"))
+	}
+	if err := do("a"); err != ErrCircuitOpen || len(h.requests()) != 2 {
+		t.Fatal("Intercept: request of open circuit", err, h.requests())
+	}
+	if err := do("b"); err != nil || b.State("b") != CircuitClosed { // 不影响其他域名
+		t.Fatal("Intercept b:", err)
+	}
+
+	clock.Add(29 * time.Second)
+	if b.State("a") != CircuitOpen {
+		t.Fatal("State: half-open before cool down", b.State("a"))
+	}
+	clock.Add(time.Second)
+	if b.State("a") != CircuitHalfOpen || !b.Available("a") {
+		t.Fatal("State: not half-open after cool down", b.State("a"))
+	}
+	do("a") // 试探请求失败
+	if b.State("a") != CircuitOpen {
+		t.Fatal("State: failed probe", b.State("a"))
+	}
+	clock.Add(30 * time.Second)
+	if err := do("a"); err != nil || b.State("a") != CircuitClosed {
+		t.Fatal("State: succeeded probe", err, b.State("a"))
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	want := "a:closed>open,a:open>half-open,a:half-open>open,a:open>half-open,a:half-open>closed"
+	if got := strings.Join(events, ","); got != want {
+		t.Fatal("OnStateChange:", got)
+	}
+}
+
+func TestCircuitBreakerFailures(t *testing.T) {
+	cases := []struct {
+		name   string
+		codes  []int
+		window time.Duration
+		wait   time.Duration // 两次请求之间的等待
+		state  CircuitState
+	}{
+		{"failed", []int{502, 0}, time.Second, 0, CircuitOpen},
+		{"rate-limited", []int{573, 429}, time.Second, 0, CircuitClosed},
+		{"client-errors", []int{404, 403}, time.Second, 0, CircuitClosed},
+		{"ratio", []int{200, 502}, time.Second, 0, CircuitOpen},
+		{"in-window", []int{502, 502}, 10 * time.Second, 10 * time.Second, CircuitOpen},
+		{"window", []int{502, 502}, 10 * time.Second, 11 * time.Second, CircuitClosed},
+	}
+	for _, c := range cases {
+		clock := &fakeClock{now: time.Unix(1700000000, 0)}
+		b := NewCircuitBreaker(CircuitBreakerConfig{MinRequests: 2, Window: c.window})
+		b.now = clock.Now
+		interceptor := b.Interceptor()
+		h := &stubHandler{codes: c.codes}
+		for range c.codes {
+			interceptor.Intercept(newRequest(t, "http://a/stat"), h.handle)
+			clock.Add(c.wait)
+		}
+		if state := b.State("a"); state != c.state {
+			t.Errorf("%s: state %v", c.name, state)
+		}
+	}
+}
@@ -85,17 +85,23 @@ func (interceptor *debugInterceptor) Intercept(req *http.Request, handler Handle
 		return handler(req)
 	}
 
+	// 调试信息输出到请求的 logger（见 NewLogInterceptor），没有时不输出
+	l := log.FromContext(req.Context())
+	if !l.Enabled(log.LogDebug) {
+		return handler(req)
+	}
+
 	label := interceptor.requestLabel(req)
 
-	if e := interceptor.printRequest(label, req); e != nil {
+	if e := interceptor.printRequest(l, label, req); e != nil {
 		return nil, e
 	}
 
-	req = interceptor.printRequestTrace(label, req)
+	req = interceptor.printRequestTrace(l, label, req)
 
 	resp, err := handler(req)
 
-	if e := interceptor.printResponse(label, resp); e != nil {
+	if e := interceptor.printResponse(l, label, resp); e != nil {
 		return nil, e
 	}
 
@@ -106,10 +112,10 @@ func (interceptor *debugInterceptor) requestLabel(req *http.Request) string {
 	if req == nil || req.URL == nil {
 		return ""
 	}
-	return fmt.Sprintf("Url:%s", req.URL.String())
+	return fmt.Sprintf("Url:%s", log.Redact(req.URL.String()))
 }
 
-func (interceptor *debugInterceptor) printRequest(label string, req *http.Request) error {
+func (interceptor *debugInterceptor) printRequest(l log.FieldLogger, label string, req *http.Request) error {
 	if req == nil {
 		return nil
 	}
@@ -124,13 +130,13 @@ func (interceptor *debugInterceptor) printRequest(label string, req *http.Reques
 	if dErr != nil {
 		return dErr
 	}
-	info += string(d) + "\n"
+	info += log.Redact(string(d)) + "\n"
 
-	log.Debug(info)
+	l.Log(log.LogDebug, info)
 	return nil
 }
 
-func (interceptor *debugInterceptor) printRequestTrace(label string, req *http.Request) *http.Request {
+func (interceptor *debugInterceptor) printRequestTrace(l log.FieldLogger, label string, req *http.Request) *http.Request {
 	if !IsPrintRequestTrace() || req == nil {
 		return req
 	}
@@ -138,57 +144,57 @@ func (interceptor *debugInterceptor) printRequestTrace(label string, req *http.R
 	label += "\n"
 	trace := &httptrace.ClientTrace{
 		GetConn: func(hostPort string) {
-			log.Debug(label + fmt.Sprintf("GetConn, %s \n", hostPort))
+			l.Log(log.LogDebug, label+fmt.Sprintf("GetConn, %s \n", hostPort))
 		},
 		GotConn: func(connInfo httptrace.GotConnInfo) {
 			remoteAddr := connInfo.Conn.RemoteAddr()
-			log.Debug(label + fmt.Sprintf("GotConn, Network:%s RemoteAddr:%s \n", remoteAddr.Network(), remoteAddr.String()))
+			l.Log(log.LogDebug, label+fmt.Sprintf("GotConn, Network:%s RemoteAddr:%s \n", remoteAddr.Network(), remoteAddr.String()))
 		},
 		PutIdleConn: func(err error) {
-			log.Debug(label + fmt.Sprintf("PutIdleConn, err:%v \n", err))
+			l.Log(log.LogDebug, label+fmt.Sprintf("PutIdleConn, err:%v \n", err))
 		},
 		GotFirstResponseByte: func() {
-			log.Debug(label + "GotFirstResponseByte \n")
+			l.Log(log.LogDebug, label+"GotFirstResponseByte \n")
 		},
 		Got100Continue: func() {
-			log.Debug(label + "Got100Continue \n")
+			l.Log(log.LogDebug, label+"Got100Continue \n")
 		},
 		DNSStart: func(info httptrace.DNSStartInfo) {
-			log.Debug(label + fmt.Sprintf("DNSStart, host:%s \n", info.Host))
+			l.Log(log.LogDebug, label+fmt.Sprintf("DNSStart, host:%s \n", info.Host))
 		},
 		DNSDone: func(info httptrace.DNSDoneInfo) {
-			log.Debug(label + fmt.Sprintf("DNSDone, addr:%+v \n", info.Addrs))
+			l.Log(log.LogDebug, label+fmt.Sprintf("DNSDone, addr:%+v \n", info.Addrs))
 		},
 		ConnectStart: func(network, addr string) {
-			log.Debug(label + fmt.Sprintf("ConnectStart, network:%+v ip:%s \n", network, addr))
+			l.Log(log.LogDebug, label+fmt.Sprintf("ConnectStart, network:%+v ip:%s \n", network, addr))
 		},
 		ConnectDone: func(network, addr string, err error) {
-			log.Debug(label + fmt.Sprintf("ConnectDone, network:%s ip:%s err:%v \n", network, addr, err))
+			l.Log(log.LogDebug, label+fmt.Sprintf("ConnectDone, network:%s ip:%s err:%v \n", network, addr, err))
 		},
 		TLSHandshakeStart: func() {
-			log.Debug(label + "TLSHandshakeStart \n")
+			l.Log(log.LogDebug, label+"TLSHandshakeStart \n")
 		},
 		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
-			log.Debug(label + fmt.Sprintf("TLSHandshakeDone, state:%+v err:%s \n", state, err))
+			l.Log(log.LogDebug, label+fmt.Sprintf("TLSHandshakeDone, state:%+v err:%s \n", state, err))
 		},
 		// go1.10 不支持
 		//WroteHeaderField: func(key string, value []string) {
 		//	log.Debug(label + fmt.Sprintf("WroteHeaderField, key:%s value:%s \n", key, value))
 		//},
 		WroteHeaders: func() {
-			log.Debug(label + "WroteHeaders \n")
+			l.Log(log.LogDebug, label+"WroteHeaders \n")
 		},
 		Wait100Continue: func() {
-			log.Debug(label + "Wait100Continue \n")
+			l.Log(log.LogDebug, label+"Wait100Continue \n")
 		},
 		WroteRequest: func(info httptrace.WroteRequestInfo) {
-			log.Debug(label + fmt.Sprintf("WroteRequest, err:%v \n", info.Err))
+			l.Log(log.LogDebug, label+fmt.Sprintf("WroteRequest, err:%v \n", info.Err))
 		},
 	}
 	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
 }
 
-func (interceptor *debugInterceptor) printResponse(label string, resp *http.Response) error {
+func (interceptor *debugInterceptor) printResponse(l log.FieldLogger, label string, resp *http.Response) error {
 	if resp == nil {
 		return nil
 	}
@@ -203,8 +209,8 @@ func (interceptor *debugInterceptor) printResponse(label string, resp *http.Resp
 	if dErr != nil {
 		return dErr
 	}
-	info += string(d) + "\n"
+	info += log.Redact(string(d)) + "\n"
 
-	log.Debug(info)
+	l.Log(log.LogDebug, info)
 	return nil
 }
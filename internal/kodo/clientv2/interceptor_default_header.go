@@ -6,6 +6,7 @@ import (
 
 	clientV1 "github.com/xushiwei/kodofs/internal/kodo/client"
 	"github.com/xushiwei/kodofs/internal/kodo/conf"
+	"github.com/xushiwei/kodofs/internal/kodo/reqid"
 )
 
 type defaultHeaderInterceptor struct {
@@ -36,9 +37,18 @@ func (interceptor *defaultHeaderInterceptor) Intercept(req *http.Request, handle
 		return nil, e
 	}
 
+	addReqid(req)
+
 	return handler(req)
 }
 
+// addReqid 发送调用方通过 reqid.WithReqid 指定的请求 ID
+func addReqid(req *http.Request) {
+	if reqId, ok := reqid.ReqidFromContext(req.Context()); ok && reqId != "" {
+		req.Header.Set("X-Reqid", reqId)
+	}
+}
+
 func addUseragent(headers http.Header) error {
 	headers.Set("User-Agent", clientV1.UserAgent)
 	return nil
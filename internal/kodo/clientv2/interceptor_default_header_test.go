@@ -0,0 +1,27 @@
+package clientv2
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/xushiwei/kodofs/internal/kodo/reqid"
+)
+
+func TestDefaultHeaderReqid(t *testing.T) {
+	interceptor := newDefaultHeaderInterceptor()
+	var got http.Header
+	handler := func(req *http.Request) (*http.Response, error) {
+		got = req.Header.Clone()
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	}
+
+	req := newRequest(t, "http://rs/stat")
+	interceptor.Intercept(req.WithContext(reqid.WithReqid(req.Context(), "test-reqid")), handler)
+	if got.Get("X-Reqid") != "test-reqid" || got.Get("User-Agent") == "" {
+		t.Fatal("header:", got)
+	}
+	interceptor.Intercept(newRequest(t, "http://rs/stat"), handler)
+	if _, ok := got["X-Reqid"]; ok {
+		t.Fatal("header: X-Reqid without a reqid", got)
+	}
+}
@@ -0,0 +1,149 @@
+package clientv2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/xushiwei/kodofs/internal/kodo/hostprovider"
+)
+
+type HedgeConfig struct {
+	// 请求发出后经过 Delay 仍未返回时，向另一个域名发送对冲请求，先返回者胜出
+	Delay time.Duration
+
+	// 对冲请求的域名从 HostProvider 中 host 以外的可用域名中选择
+	HostProvider hostprovider.HostProvider
+
+	// 可选，判断请求是否可以对冲，默认只对冲幂等的 GET、HEAD 请求及列举请求
+	ShouldHedge func(req *http.Request) bool
+}
+
+func (c *HedgeConfig) init() {
+	if c.ShouldHedge == nil {
+		c.ShouldHedge = func(req *http.Request) bool {
+			if req.Method == http.MethodGet || req.Method == http.MethodHead {
+				return true
+			}
+			class, _ := APIClassFromContext(req.Context())
+			return class == APIClassList && (req.Body == nil || req.Body == http.NoBody)
+		}
+	}
+}
+
+type hedgeInterceptor struct {
+	config HedgeConfig
+}
+
+// NewHedgeInterceptor 返回对冲请求拦截器，其位于主备域名重试之外，每个对冲请求有各自的重试
+func NewHedgeInterceptor(config HedgeConfig) Interceptor {
+	config.init()
+	return &hedgeInterceptor{config: config}
+}
+
+func (interceptor *hedgeInterceptor) Priority() InterceptorPriority {
+	return InterceptorPriorityHedge
+}
+
+type hedgeResult struct {
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+}
+
+// final 判断请求结果是否确定（成功或不可重试的错误），无需等待另一个请求
+func (r *hedgeResult) final() bool {
+	return r.err == nil && r.resp != nil && !isResponseRetryable(r.resp)
+}
+
+// result 返回请求结果，resp.Body 被关闭时释放请求的 context
+func (r *hedgeResult) result() (*http.Response, error) {
+	if r.resp != nil && r.resp.Body != nil {
+		r.resp.Body = &cancelOnClose{r.resp.Body, r.cancel}
+	} else {
+		r.cancel()
+	}
+	return r.resp, r.err
+}
+
+func (r *hedgeResult) discard() {
+	r.cancel()
+	closeResponse(r.resp)
+}
+
+func (interceptor *hedgeInterceptor) Intercept(req *http.Request, handler Handler) (*http.Response, error) {
+	if interceptor == nil || req == nil {
+		return handler(req)
+	}
+	config := &interceptor.config
+	if config.Delay <= 0 || config.HostProvider == nil || !config.ShouldHedge(req) {
+		return handler(req)
+	}
+
+	ctx := req.Context()
+	results := make(chan *hedgeResult, 2)
+	send := func(r *http.Request) {
+		rctx, cancel := context.WithCancel(ctx)
+		resp, err := handler(r.WithContext(rctx))
+		results <- &hedgeResult{resp, err, cancel}
+	}
+	hedgeReq := cloneReq(ctx, req) // 在 handler 修改 req 之前复制
+	go send(req)
+
+	timer := time.NewTimer(config.Delay)
+	defer timer.Stop()
+	pending := 1
+	var failed *hedgeResult // 对冲中先失败的请求
+	for {
+		select {
+		case <-timer.C:
+			if host, err := hostprovider.Other(config.HostProvider, req.URL.Host); err == nil {
+				hedgeReq.URL = replaceHost(hedgeReq.URL, host)
+				hedgeReq.Host = hedgeReq.URL.Host
+				pending++
+				go send(hedgeReq)
+			}
+		case r := <-results:
+			pending--
+			if r.final() || pending == 0 {
+				if failed != nil {
+					failed.discard()
+				}
+				go drainHedge(results, pending) // 关闭落败的请求
+				return r.result()
+			}
+			failed = r // 等待另一个请求
+		}
+	}
+}
+
+// drainHedge 关闭落败的对冲请求
+func drainHedge(results chan *hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		(<-results).discard()
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (p *cancelOnClose) Close() error {
+	err := p.ReadCloser.Close()
+	p.cancel()
+	return err
+}
+
+// replaceHost 替换 u 的域名，host 可带 scheme
+func replaceHost(u *url.URL, host string) *url.URL {
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	ret := *u
+	ret.Host = host
+	return &ret
+}
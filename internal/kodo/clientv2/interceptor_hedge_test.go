@@ -0,0 +1,78 @@
+package clientv2
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xushiwei/kodofs/internal/kodo/hostprovider"
+)
+
+// a 的请求如何等待对冲请求
+const (
+	hedgeNoWait = iota // 立即返回
+	hedgeWait          // 对冲请求到达后返回
+	hedgeBlock         // 被取消前不返回
+)
+
+func TestHedge(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		hosts  []string
+		codes  []int
+		delay  time.Duration
+		wait   int
+		code   int
+		hedged bool // 是否发出了对冲请求
+	}{
+		{"hedged", http.MethodGet, []string{"a", "b"}, nil, time.Millisecond, hedgeBlock, 200, true},
+		{"fast", http.MethodGet, []string{"a", "b"}, nil, time.Hour, hedgeNoWait, 200, false},
+		{"not-idempotent", http.MethodPost, []string{"a", "b"}, nil, time.Millisecond, hedgeNoWait, 200, false},
+		{"no-other-host", http.MethodGet, []string{"a"}, nil, time.Millisecond, hedgeNoWait, 200, false},
+		{"both-failed", http.MethodGet, []string{"a", "b"}, []int{502, 503}, time.Millisecond, hedgeWait, 502, true},
+		{"hedge-failed", http.MethodGet, []string{"a", "b"}, []int{200, 502}, time.Millisecond, hedgeWait, 200, true},
+	}
+	for _, c := range cases {
+		h := &stubHandler{codes: c.codes}
+		wait, hedged := c.wait, make(chan struct{})
+		var once sync.Once
+		handler := func(req *http.Request) (*http.Response, error) {
+			resp, err := h.handle(req)
+			if req.URL.Host != "a" {
+				once.Do(func() { close(hedged) })
+				return resp, err
+			}
+			ctx := req.Context()
+			switch wait {
+			case hedgeWait:
+				select {
+				case <-hedged:
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			case hedgeBlock:
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return resp, err
+		}
+		interceptor := NewHedgeInterceptor(HedgeConfig{
+			Delay:        c.delay,
+			HostProvider: hostprovider.New(c.hosts, hostprovider.Options{}),
+		})
+		req, _ := http.NewRequest(c.method, "http://a/key", nil)
+		resp, err := interceptor.Intercept(req, handler)
+		if err != nil || resp.StatusCode != c.code {
+			t.Errorf("%s: %v %v", c.name, resp, err)
+			continue
+		}
+		resp.Body.Close()
+		hosts := strings.Join(h.requests(), ",")
+		if hedged := hosts == "a,b"; hedged != c.hedged {
+			t.Errorf("%s: hosts %s", c.name, hosts)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package clientv2
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"github.com/xushiwei/kodofs/internal/kodo/log"
+)
+
+type logInterceptor struct {
+	logger log.FieldLogger
+}
+
+// NewLogInterceptor 返回通过 logger 记录每次请求的拦截器，其位于鉴权之内，
+// 成功的请求以 Debug 级别记录，失败或 5xx 的请求以 Warn 级别记录；Authorization 头、上传凭证及 AK 不会被记录。
+// 输出 Debug 级别日志时，打开了调试输出（见 PrintRequest 等）的请求的 dump 也输出到 logger
+func NewLogInterceptor(logger log.FieldLogger) Interceptor {
+	return &logInterceptor{logger: logger}
+}
+
+func (interceptor *logInterceptor) Priority() InterceptorPriority {
+	return InterceptorPriorityLog
+}
+
+func (interceptor *logInterceptor) Intercept(req *http.Request, handler Handler) (*http.Response, error) {
+	if interceptor == nil || interceptor.logger == nil || req == nil {
+		return handler(req)
+	}
+
+	l := interceptor.logger
+	debug := l.Enabled(log.LogDebug)
+	if !debug && !l.Enabled(log.LogWarn) {
+		return handler(req)
+	}
+	if debug {
+		req = req.WithContext(log.WithLogger(req.Context(), l)) // 输出调试拦截器的 dump
+	}
+
+	var dump string
+	if debug {
+		if d, err := httputil.DumpRequest(req, false); err == nil {
+			dump = log.Redact(string(d))
+		}
+	}
+	start := time.Now()
+	resp, err := handler(req)
+	keyvals := []interface{}{
+		"method", req.Method,
+		"url", log.Redact(req.URL.String()),
+	}
+	level := log.LogDebug
+	if err != nil {
+		level = log.LogWarn
+		keyvals = append(keyvals, "err", log.Redact(err.Error())) // 错误信息可能含有私有下载链接
+	} else if resp != nil {
+		if resp.StatusCode >= 500 {
+			level = log.LogWarn
+		}
+		keyvals = append(keyvals, "status", resp.StatusCode, "reqid", resp.Header.Get("X-Reqid"))
+	}
+	keyvals = append(keyvals, "latency", time.Since(start))
+	if dump != "" {
+		keyvals = append(keyvals, "request", dump)
+	}
+	l.Log(level, "kodo: http request", keyvals...)
+	return resp, err
+}
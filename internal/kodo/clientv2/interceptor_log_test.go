@@ -0,0 +1,113 @@
+package clientv2
+
+import (
+	stdlog "log"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/xushiwei/kodofs/internal/kodo/log"
+)
+
+const testToken = "test-ak:c2lnbmF0dXJl"
+
+func newTestLogger(level log.LogLevel) (log.FieldLogger, *strings.Builder) {
+	var buf strings.Builder
+	return log.NewFieldLogger(stdlog.New(&buf, "", 0), level), &buf
+}
+
+func TestLogInterceptor(t *testing.T) {
+	privateURL := "http://io/a.txt?e=1700000000&token=" + testToken
+	cases := []struct {
+		name  string
+		level log.LogLevel
+		codes []int
+		logs  []string // 日志中应有的内容
+	}{
+		{"debug", log.LogDebug, nil, []string{"[D] kodo: http request", "status=200", "token=REDACTED", "Authorization: Qiniu REDACTED"}},
+		{"warn-ok", log.LogWarn, nil, nil},
+		{"warn-5xx", log.LogWarn, []int{502}, []string{"[W] kodo: http request", "status=502", "token=REDACTED"}},
+		{"warn-error", log.LogWarn, []int{0}, []string{"[W] kodo: http request", "err=", "token=REDACTED"}},
+	}
+	for _, c := range cases {
+		logger, buf := newTestLogger(c.level)
+		interceptor := NewLogInterceptor(logger)
+		h := &stubHandler{codes: c.codes}
+		handler := func(req *http.Request) (*http.Response, error) {
+			resp, err := h.handle(req)
+			if err != nil { // 与 http.Client 一样，错误信息中有请求的 url
+				err = &url.Error{Op: "Get", URL: req.URL.String(), Err: err}
+			}
+			return resp, err
+		}
+		req := newRequest(t, privateURL)
+		req.Header.Set("Authorization", "Qiniu "+testToken)
+		interceptor.Intercept(req, handler)
+
+		logs := buf.String()
+		if len(c.logs) == 0 && logs != "" {
+			t.Errorf("%s: unexpected logs:\n%s", c.name, logs)
+		}
+		for _, s := range c.logs {
+			if !strings.Contains(logs, s) {
+				t.Errorf("%s: no %q in logs:\n%s", c.name, s, logs)
+			}
+		}
+		if strings.Contains(logs, testToken) {
+			t.Errorf("%s: token in logs:\n%s", c.name, logs)
+		}
+	}
+}
+
+func TestDebugInterceptor(t *testing.T) {
+	PrintRequest(DebugLevelPrintNormal)
+	PrintResponse(DebugLevelPrintNormal)
+	defer func() {
+		PrintRequest(DebugLevelPrintNone)
+		PrintResponse(DebugLevelPrintNone)
+	}()
+
+	// 调试拦截器的 dump 输出到日志拦截器的 logger
+	logger, buf := newTestLogger(log.LogDebug)
+	logInterceptor, debug := NewLogInterceptor(logger), newDebugInterceptor()
+	h := &stubHandler{}
+	req := newRequest(t, "http://uc/v4/query?ak=test-ak&bucket=bkt")
+	req.Header.Set("Authorization", "Qiniu "+testToken)
+	logInterceptor.Intercept(req, func(req *http.Request) (*http.Response, error) {
+		return debug.Intercept(req, h.handle)
+	})
+	logs := buf.String()
+	for _, s := range []string{"request:", "response:", "ak=REDACTED", "Authorization: Qiniu REDACTED"} {
+		if !strings.Contains(logs, s) {
+			t.Errorf("no %q in logs:\n%s", s, logs)
+		}
+	}
+	if strings.Contains(logs, testToken) || strings.Contains(logs, "test-ak") {
+		t.Errorf("credentials in logs:\n%s", logs)
+	}
+
+	// 没有 logger 的请求不输出
+	if _, err := debug.Intercept(newRequest(t, "http://uc/v4/query"), h.handle); err != nil {
+		t.Fatal("Intercept:", err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	cases := []struct {
+		in, out string
+	}{
+		{"Authorization: Qiniu ak:sign\r\nHost: rs", "Authorization: Qiniu REDACTED\r\nHost: rs"},
+		{"authorization: UpToken ak:sign:policy", "authorization: UpToken REDACTED"},
+		{`Get "http://io/a?e=1&token=ak:sign": EOF`, `Get "http://io/a?e=1&token=REDACTED": EOF`},
+		{"/v4/query?ak=ak&bucket=bkt", "/v4/query?ak=REDACTED&bucket=bkt"},
+		{"/a?uploadToken=t#x", "/a?uploadToken=REDACTED#x"},
+		{"Content-Disposition: form-data; name=\"token\"\r\n\r\nak:sign:policy\r\n", "Content-Disposition: form-data; name=\"token\"\r\n\r\nREDACTED\r\n"},
+		{"/a?mytoken=t", "/a?mytoken=t"},
+	}
+	for _, c := range cases {
+		if out := log.Redact(c.in); out != c.out {
+			t.Errorf("Redact(%q) = %q", c.in, out)
+		}
+	}
+}
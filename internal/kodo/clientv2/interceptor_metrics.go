@@ -0,0 +1,111 @@
+package clientv2
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics 收集请求指标，其方法会被并发调用，且不应阻塞
+type Metrics interface {
+	// Request 在每次 HTTP 请求（每次重试都是一次请求）收到响应头或失败时调用，请求失败时 code 为 0
+	Request(class APIClass, host string, code int, latency time.Duration)
+
+	// Retry 在请求被重试前调用，host 为失败的域名
+	Retry(class APIClass, host string)
+
+	// HostFrozen 在域名被冻结时调用
+	HostFrozen(class APIClass, host string)
+
+	// BytesSent 在请求体发送完毕后调用
+	BytesSent(class APIClass, host string, n int64)
+
+	// BytesReceived 在响应体被关闭时调用
+	BytesReceived(class APIClass, host string, n int64)
+}
+
+type metricsInterceptor struct {
+	metrics Metrics
+}
+
+// NewMetricsInterceptor 返回收集请求指标的拦截器，其位于重试之内，记录每次请求
+func NewMetricsInterceptor(metrics Metrics) Interceptor {
+	return &metricsInterceptor{metrics: metrics}
+}
+
+func (interceptor *metricsInterceptor) Priority() InterceptorPriority {
+	return InterceptorPriorityMetrics
+}
+
+func (interceptor *metricsInterceptor) Intercept(req *http.Request, handler Handler) (*http.Response, error) {
+	if interceptor == nil || interceptor.metrics == nil || req == nil {
+		return handler(req)
+	}
+
+	m := interceptor.metrics
+	class, _ := APIClassFromContext(req.Context())
+	host := req.URL.Host
+	var sent *countingBody
+	if req.Body != nil && req.Body != http.NoBody {
+		sent = &countingBody{ReadCloser: req.Body}
+		req.Body = sent
+	}
+	start := time.Now()
+	resp, err := handler(req)
+	code := 0
+	if err == nil && resp != nil {
+		code = resp.StatusCode
+	}
+	m.Request(class, host, code, time.Since(start))
+	if sent != nil {
+		if n := atomic.LoadInt64(&sent.n); n > 0 {
+			m.BytesSent(class, host, n)
+		}
+	}
+	if resp != nil && resp.Body != nil && resp.Body != http.NoBody {
+		resp.Body = &countingBody{ReadCloser: resp.Body, onClose: func(n int64) {
+			m.BytesReceived(class, host, n)
+		}}
+	}
+	return resp, err
+}
+
+// reportRetry 通过 metrics（可为 nil）记录请求 req 被重试
+func reportRetry(metrics Metrics, req *http.Request) {
+	if metrics != nil {
+		class, _ := APIClassFromContext(req.Context())
+		metrics.Retry(class, req.URL.Host)
+	}
+}
+
+// reportHostFrozen 通过 metrics（可为 nil）记录请求 req 的域名被冻结
+func reportHostFrozen(metrics Metrics, req *http.Request) {
+	if metrics != nil {
+		class, _ := APIClassFromContext(req.Context())
+		metrics.HostFrozen(class, req.URL.Host)
+	}
+}
+
+// countingBody 统计读取的字节数，关闭时调用 onClose（只调用一次）
+type countingBody struct {
+	io.ReadCloser
+	n       int64
+	onClose func(n int64)
+	once    sync.Once
+}
+
+func (p *countingBody) Read(b []byte) (n int, err error) {
+	n, err = p.ReadCloser.Read(b)
+	atomic.AddInt64(&p.n, int64(n))
+	return
+}
+
+func (p *countingBody) Close() error {
+	err := p.ReadCloser.Close()
+	if p.onClose != nil {
+		p.once.Do(func() { p.onClose(atomic.LoadInt64(&p.n)) })
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package clientv2
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xushiwei/kodofs/internal/kodo/hostprovider"
+)
+
+// recordingMetrics 记录指标事件
+type recordingMetrics struct {
+	events []string
+	mutex  sync.Mutex
+}
+
+func (p *recordingMetrics) add(format string, args ...interface{}) {
+	p.mutex.Lock()
+	p.events = append(p.events, fmt.Sprintf(format, args...))
+	p.mutex.Unlock()
+}
+
+func (p *recordingMetrics) Request(class APIClass, host string, code int, latency time.Duration) {
+	p.add("request %s %s %d", class, host, code)
+}
+
+func (p *recordingMetrics) Retry(class APIClass, host string) {
+	p.add("retry %s %s", class, host)
+}
+
+func (p *recordingMetrics) HostFrozen(class APIClass, host string) {
+	p.add("frozen %s %s", class, host)
+}
+
+func (p *recordingMetrics) BytesSent(class APIClass, host string, n int64) {
+	p.add("sent %s %s %d", class, host, n)
+}
+
+func (p *recordingMetrics) BytesReceived(class APIClass, host string, n int64) {
+	p.add("received %s %s %d", class, host, n)
+}
+
+func (p *recordingMetrics) String() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return strings.Join(p.events, ",")
+}
+
+func TestMetrics(t *testing.T) {
+	m := &recordingMetrics{}
+	metrics := NewMetricsInterceptor(m)
+	retry := NewHostsRetryInterceptor(HostsRetryConfig{
+		RetryConfig:  RetryConfig{RetryMax: 1, Backoff: &Backoff{Base: time.Millisecond}, Metrics: m},
+		HostProvider: hostprovider.New([]string{"a", "b"}, hostprovider.Options{}),
+	})
+	h := &stubHandler{codes: []int{0}}
+	handler := func(req *http.Request) (*http.Response, error) {
+		return metrics.Intercept(req, func(req *http.Request) (*http.Response, error) {
+			io.Copy(io.Discard, req.Body)
+			resp, err := h.handle(req)
+			if err == nil {
+				resp.Body = io.NopCloser(strings.NewReader("hello"))
+			}
+			return resp, err
+		})
+	}
+
+	ctx := WithAPIClass(context.Background(), APIClassUpload)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "http://a/", bytes.NewReader([]byte("abc")))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader([]byte("abc"))), nil
+	}
+	resp, err := retry.Intercept(req, handler)
+	if err != nil {
+		t.Fatal("Intercept:", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	resp.Body.Close() // 只记录一次
+
+	want := "request upload a 0,sent upload a 3,frozen upload a,retry upload a," +
+		"request upload b 200,sent upload b 3,received upload b 5"
+	if got := m.String(); got != want {
+		t.Fatal("events:", got)
+	}
+}
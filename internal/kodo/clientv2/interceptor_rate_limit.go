@@ -0,0 +1,191 @@
+package clientv2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// APIClass 接口类别，用于按类别限制请求速率
+type APIClass string
+
+const (
+	APIClassList   APIClass = "list"   // rsf 列举
+	APIClassUpload APIClass = "upload" // up 上传
+	APIClassRs     APIClass = "rs"     // rs 等管理接口
+	APIClassIo     APIClass = "io"     // io 下载
+	APIClassUc     APIClass = "uc"     // uc 查询空间所在区域
+)
+
+type apiClassKey struct{}
+
+// WithAPIClass 返回标记了接口类别的 context
+func WithAPIClass(ctx context.Context, class APIClass) context.Context {
+	return context.WithValue(ctx, apiClassKey{}, class)
+}
+
+// APIClassFromContext 返回 context 标记的接口类别
+func APIClassFromContext(ctx context.Context) (class APIClass, ok bool) {
+	class, ok = ctx.Value(apiClassKey{}).(APIClass)
+	return
+}
+
+// Rate 速率限制，Limit 为每秒的请求数或字节数，Burst 为允许的突发量（默认：Limit，且至少为 1）
+type Rate struct {
+	Limit float64
+	Burst int
+}
+
+type RateLimitConfig struct {
+	// 各类接口的请求速率，未指定的类别（及未标记类别的请求）不限制
+	Requests map[APIClass]Rate
+
+	// 响应体的读取速率（字节/秒），Limit 为 0 时不限制
+	ReadBytes Rate
+
+	// 请求体的写入速率（字节/秒），Limit 为 0 时不限制
+	WriteBytes Rate
+}
+
+// RateLimiter 客户端限流：按接口类别限制请求速率，并限制请求体与响应体的传输速率。
+// 一个 RateLimiter 可被多个 client 共用，以限制它们的总速率
+type RateLimiter struct {
+	requests   map[APIClass]*tokenBucket
+	readBytes  *tokenBucket
+	writeBytes *tokenBucket
+}
+
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	return newRateLimiter(config, time.Now)
+}
+
+// newRateLimiter 返回按 now 计时补充令牌的 RateLimiter
+func newRateLimiter(config RateLimitConfig, now func() time.Time) *RateLimiter {
+	l := &RateLimiter{
+		requests:   make(map[APIClass]*tokenBucket),
+		readBytes:  newTokenBucket(config.ReadBytes, now),
+		writeBytes: newTokenBucket(config.WriteBytes, now),
+	}
+	for class, rate := range config.Requests {
+		if b := newTokenBucket(rate, now); b != nil {
+			l.requests[class] = b
+		}
+	}
+	return l
+}
+
+// Wait 等待 class 类接口的一个请求配额，ctx 被取消时返回其错误
+func (l *RateLimiter) Wait(ctx context.Context, class APIClass) error {
+	if b, ok := l.requests[class]; ok {
+		return b.wait(ctx, 1)
+	}
+	return nil
+}
+
+// Interceptor 返回限流拦截器，其位于单域名重试之内，每次重试都会占用配额
+func (l *RateLimiter) Interceptor() Interceptor {
+	return &rateLimitInterceptor{l}
+}
+
+type rateLimitInterceptor struct {
+	limiter *RateLimiter
+}
+
+func (interceptor *rateLimitInterceptor) Priority() InterceptorPriority {
+	return InterceptorPriorityRateLimit
+}
+
+func (interceptor *rateLimitInterceptor) Intercept(req *http.Request, handler Handler) (*http.Response, error) {
+	if interceptor == nil || req == nil {
+		return handler(req)
+	}
+
+	l, ctx := interceptor.limiter, req.Context()
+	if class, ok := APIClassFromContext(ctx); ok {
+		if err := l.Wait(ctx, class); err != nil {
+			return nil, err
+		}
+	}
+	if l.writeBytes != nil && req.Body != nil && req.Body != http.NoBody {
+		req.Body = &rateLimitedBody{req.Body, ctx, l.writeBytes}
+	}
+	resp, err := handler(req)
+	if l.readBytes != nil && resp != nil && resp.Body != nil && resp.Body != http.NoBody {
+		resp.Body = &rateLimitedBody{resp.Body, ctx, l.readBytes}
+	}
+	return resp, err
+}
+
+type rateLimitedBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	bucket *tokenBucket
+}
+
+func (p *rateLimitedBody) Read(b []byte) (n int, err error) {
+	if burst := p.bucket.burst; len(b) > burst {
+		b = b[:burst]
+	}
+	n, err = p.ReadCloser.Read(b)
+	if n > 0 {
+		if wErr := p.bucket.wait(p.ctx, n); wErr != nil && err == nil {
+			err = wErr
+		}
+	}
+	return
+}
+
+// -----------------------------------------------------------------------------------------
+
+// tokenBucket 令牌桶，可透支：等待的令牌数超过当前令牌数时，等待至令牌补足
+type tokenBucket struct {
+	rate   float64 // 每秒补充的令牌数
+	burst  int
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	mutex  sync.Mutex
+}
+
+func newTokenBucket(rate Rate, now func() time.Time) *tokenBucket {
+	if rate.Limit <= 0 {
+		return nil
+	}
+	burst := rate.Burst
+	if burst <= 0 {
+		burst = int(rate.Limit)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate.Limit, burst: burst, tokens: float64(burst), last: now(), now: now}
+}
+
+func (b *tokenBucket) wait(ctx context.Context, n int) error {
+	if err := Sleep(ctx, b.reserve(n)); err != nil {
+		b.mutex.Lock()
+		b.tokens += float64(n) // 归还未使用的令牌
+		b.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// reserve 取出 n 个令牌，返回令牌补足前需要等待的时间
+func (b *tokenBucket) reserve(n int) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if max := float64(b.burst); b.tokens > max {
+		b.tokens = max
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,88 @@
+package clientv2
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitRequests(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	l := newRateLimiter(RateLimitConfig{Requests: map[APIClass]Rate{APIClassList: {Limit: 2, Burst: 1}}}, clock.Now)
+	interceptor := l.Interceptor()
+	h := &stubHandler{}
+	do := func(ctx context.Context) error {
+		req := newRequest(t, "http://rsf/list").WithContext(ctx)
+		_, err := interceptor.Intercept(req, h.handle)
+		return err
+	}
+
+	bucket := l.requests[APIClassList]
+	for i := 0; i < 10; i++ { // 其他类别及未标记类别的请求不限制
+		do(WithAPIClass(context.Background(), APIClassRs))
+		do(context.Background())
+	}
+	if len(l.requests) != 1 || bucket.tokens != 1 || len(h.requests()) != 20 {
+		t.Fatal("requests of other classes are limited:", bucket.tokens, len(h.requests()))
+	}
+
+	ctx := WithAPIClass(context.Background(), APIClassList)
+	if err := do(ctx); err != nil || bucket.tokens != 0 { // 突发
+		t.Fatal("Intercept:", err, bucket.tokens)
+	}
+	for i, want := range []time.Duration{500 * time.Millisecond, time.Second} {
+		if d := bucket.reserve(1); d != want {
+			t.Fatalf("reserve %d: got %v, want %v", i, d, want)
+		}
+	}
+	clock.Add(time.Second) // 补充 2 个令牌
+	if d := bucket.reserve(1); d != 500*time.Millisecond || bucket.tokens != -1 {
+		t.Fatal("reserve after refill:", d, bucket.tokens)
+	}
+	clock.Add(10 * time.Second) // 补充的令牌不超过 Burst
+	if d := bucket.reserve(1); d != 0 || bucket.tokens != 0 {
+		t.Fatal("reserve after burst:", d, bucket.tokens)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	n := len(h.requests())
+	if err := do(canceled); err != context.Canceled || len(h.requests()) != n || bucket.tokens != 0 {
+		t.Fatal("Intercept: canceled", err, bucket.tokens)
+	}
+}
+
+func TestRateLimitBytes(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	l := newRateLimiter(RateLimitConfig{
+		ReadBytes:  Rate{Limit: 1e6, Burst: 1000},
+		WriteBytes: Rate{Limit: 1e6, Burst: 1000},
+	}, clock.Now)
+	interceptor := l.Interceptor()
+	data := make([]byte, 4000)
+	handler := func(req *http.Request) (*http.Response, error) {
+		io.Copy(io.Discard, req.Body)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(data)), Request: req}, nil
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "http://up/", bytes.NewReader(data))
+	resp, err := interceptor.Intercept(req, handler)
+	if err != nil {
+		t.Fatal("Intercept:", err)
+	}
+	if tokens := l.writeBytes.tokens; tokens != 1000-4000 { // 时钟未前进，没有补充令牌
+		t.Fatal("write rate isn't limited:", tokens)
+	}
+
+	n, err := io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if err != nil || n != int64(len(data)) {
+		t.Fatal("Read:", n, err)
+	}
+	if tokens := l.readBytes.tokens; tokens != 1000-4000 {
+		t.Fatal("read rate isn't limited:", tokens)
+	}
+}
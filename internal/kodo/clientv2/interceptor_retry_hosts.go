@@ -2,8 +2,6 @@ package clientv2
 
 import (
 	"net/http"
-	"net/url"
-	"strings"
 	"time"
 
 	"github.com/xushiwei/kodofs/internal/kodo/hostprovider"
@@ -34,7 +32,7 @@ func (c *HostsRetryConfig) init() {
 
 	if c.ShouldFreezeHost == nil {
 		c.ShouldFreezeHost = func(req *http.Request, resp *http.Response, err error) bool {
-			return true
+			return !IsRateLimited(resp, err) // 限流不是域名故障
 		}
 	}
 }
@@ -44,6 +42,7 @@ type hostsRetryInterceptor struct {
 }
 
 func NewHostsRetryInterceptor(options HostsRetryConfig) Interceptor {
+	options.init() // 在创建时初始化，拦截器可被并发使用
 	return &hostsRetryInterceptor{
 		options: options,
 	}
@@ -58,28 +57,36 @@ func (interceptor *hostsRetryInterceptor) Intercept(req *http.Request, handler H
 		return handler(req)
 	}
 
-	interceptor.options.init()
-
 	// 不重试
 	if interceptor.options.RetryConfig.RetryMax <= 0 {
 		return handler(req)
 	}
 
+	waiter := interceptor.options.RetryConfig.newWaiter()
 	for i := 0; ; i++ {
 		// Clone 防止后面 Handler 处理对 req 有污染
 		reqBefore := cloneReq(req.Context(), req)
+		start := time.Now()
 		resp, err = handler(req)
+		if r, ok := interceptor.options.HostProvider.(hostprovider.LatencyRecorder); ok {
+			r.Record(req.URL.Host, time.Since(start), err)
+		}
 
 		if !interceptor.options.RetryConfig.ShouldRetry(reqBefore, resp, err) {
+			if u, ok := interceptor.options.HostProvider.(hostprovider.Unfreezer); ok && err == nil && resp != nil && resp.StatusCode/100 == 2 {
+				_ = u.Unfreeze(req.URL.Host) // 请求成功，域名恢复可用
+			}
 			return resp, err
 		}
 
-		// 尝试冻结域名
+		// 尝试冻结域名；熔断中的域名未发出请求，其恢复由熔断器控制，不冻结，直接更换域名
 		oldHost := req.URL.Host
-		if interceptor.options.ShouldFreezeHost(req, resp, err) {
+		circuitOpen := err == ErrCircuitOpen
+		if !circuitOpen && interceptor.options.ShouldFreezeHost(req, resp, err) {
 			if fErr := interceptor.options.HostProvider.Freeze(oldHost, err, interceptor.options.HostFreezeDuration); fErr != nil {
 				break
 			}
+			reportHostFrozen(interceptor.options.RetryConfig.Metrics, req)
 		}
 
 		if i >= interceptor.options.RetryConfig.RetryMax {
@@ -96,25 +103,21 @@ func (interceptor *hostsRetryInterceptor) Intercept(req *http.Request, handler H
 			break
 		}
 
-		if newHost != oldHost {
-			urlString := req.URL.String()
-			urlString = strings.Replace(urlString, oldHost, newHost, 1)
-			u, ppErr := url.Parse(urlString)
-			if ppErr != nil {
-				break
-			}
-
+		// 域名可带 scheme，保留请求原有的 scheme
+		if u := replaceHost(reqBefore.URL, newHost); u.Host != oldHost {
 			reqBefore.Host = u.Host
 			reqBefore.URL = u
 		}
 
-		req = reqBefore
-
-		retryInterval := interceptor.options.RetryConfig.RetryInterval()
-		if retryInterval < time.Microsecond {
-			continue
+		if !circuitOpen { // 熔断时请求未发出，无需退避
+			if ok, sErr := waiter.Wait(req.Context(), resp, err); !ok {
+				break
+			} else if sErr != nil {
+				return nil, sErr
+			}
 		}
-		time.Sleep(retryInterval)
+		reportRetry(interceptor.options.RetryConfig.Metrics, req)
+		req = reqBefore
 	}
 	return resp, err
 }
@@ -0,0 +1,93 @@
+package clientv2
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xushiwei/kodofs/internal/kodo/hostprovider"
+)
+
+func TestHostsRetry(t *testing.T) {
+	backoff := &Backoff{Base: time.Millisecond, RateLimited: time.Millisecond}
+	cases := []struct {
+		name     string
+		codes    []int
+		retryMax int
+		code     int
+		hosts    string // 请求的域名
+		frozen   string // 冻结的域名
+	}{
+		{"ok", nil, 2, 200, "a", ""},
+		{"switched", []int{502}, 2, 200, "a,b", "a"},
+		{"network-error", []int{0}, 2, 200, "a,b", "a"},
+		{"rate-limited", []int{573}, 2, 200, "a,a", ""}, // 限流不冻结域名
+		{"retry-max", []int{502, 502, 502}, 1, 502, "a,b", "a,b"},
+		{"not-retryable", []int{404}, 2, 404, "a", ""},
+		{"no-retry", []int{502}, 0, 502, "a", ""},
+	}
+	for _, c := range cases {
+		hp := hostprovider.New([]string{"a", "b"}, hostprovider.Options{})
+		h := &stubHandler{codes: c.codes}
+		interceptor := NewHostsRetryInterceptor(HostsRetryConfig{
+			RetryConfig:        RetryConfig{RetryMax: c.retryMax, Backoff: backoff},
+			HostFreezeDuration: time.Millisecond * 50,
+			HostProvider:       hp,
+		})
+		resp, err := interceptor.Intercept(newRequest(t, "http://a/stat"), h.handle)
+		if err != nil || resp.StatusCode != c.code {
+			t.Errorf("%s: %v %v", c.name, resp, err)
+			continue
+		}
+		if hosts := strings.Join(h.requests(), ","); hosts != c.hosts {
+			t.Errorf("%s: hosts %s", c.name, hosts)
+		}
+		var frozen []string
+		for _, f := range hp.(hostprovider.Inspector).Frozen() {
+			frozen = append(frozen, f.Host)
+		}
+		if strings.Join(frozen, ",") != c.frozen {
+			t.Errorf("%s: frozen %v", c.name, frozen)
+		}
+	}
+}
+
+func TestHostsRetryUnfreeze(t *testing.T) {
+	hp := hostprovider.New([]string{"a", "b"}, hostprovider.Options{})
+	hp.Freeze("a", errors.New("fail"), time.Minute)
+	interceptor := NewHostsRetryInterceptor(HostsRetryConfig{
+		RetryConfig:  RetryConfig{RetryMax: 1},
+		HostProvider: hp,
+	})
+	h := &stubHandler{}
+	if _, err := interceptor.Intercept(newRequest(t, "http://a/stat"), h.handle); err != nil {
+		t.Fatal("Intercept:", err)
+	}
+	if frozen := hp.(hostprovider.Inspector).Frozen(); len(frozen) != 0 { // a succeeds while frozen
+		t.Fatal("Frozen:", frozen)
+	}
+}
+
+func TestHostsRetryCircuitOpen(t *testing.T) {
+	available := func(host string) bool { return host != "a" } // as CircuitBreaker.Available with an open circuit of a
+	hp := hostprovider.New([]string{"a", "b"}, hostprovider.Options{Available: available})
+	interceptor := NewHostsRetryInterceptor(HostsRetryConfig{
+		RetryConfig:  RetryConfig{RetryMax: 1, Backoff: &Backoff{Base: time.Minute}},
+		HostProvider: hp,
+	})
+	h := &stubHandler{}
+	resp, err := interceptor.Intercept(newRequest(t, "http://a/stat"), func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "a" {
+			return nil, ErrCircuitOpen
+		}
+		return h.handle(req)
+	})
+	if err != nil || resp.StatusCode != 200 || strings.Join(h.requests(), ",") != "b" {
+		t.Fatal("Intercept:", resp, err, h.requests())
+	}
+	if frozen := hp.(hostprovider.Inspector).Frozen(); len(frozen) != 0 { // the breaker decides when a recovers
+		t.Fatal("Frozen:", frozen)
+	}
+}
@@ -2,7 +2,6 @@ package clientv2
 
 import (
 	"io"
-	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
@@ -16,8 +15,10 @@ import (
 
 type RetryConfig struct {
 	RetryMax      int                  // 最大重试次数
-	RetryInterval func() time.Duration // 重试时间间隔
+	RetryInterval func() time.Duration // 重试时间间隔，仅在 Backoff 为 nil 时使用
+	Backoff       *Backoff             // 重试退避策略，Backoff 与 RetryInterval 均为 nil 时使用 DefaultBackoff()
 	ShouldRetry   func(req *http.Request, resp *http.Response, err error) bool
+	Metrics       Metrics // 可选，记录重试及域名冻结
 }
 
 func (c *RetryConfig) init() {
@@ -29,10 +30,8 @@ func (c *RetryConfig) init() {
 		c.RetryMax = 0
 	}
 
-	if c.RetryInterval == nil {
-		c.RetryInterval = func() time.Duration {
-			return time.Duration(50+rand.Int()%50) * time.Millisecond
-		}
+	if c.RetryInterval == nil && c.Backoff == nil {
+		c.Backoff = DefaultBackoff()
 	}
 
 	if c.ShouldRetry == nil {
@@ -47,6 +46,7 @@ type simpleRetryInterceptor struct {
 }
 
 func NewSimpleRetryInterceptor(config RetryConfig) Interceptor {
+	config.init() // 在创建时初始化，拦截器可被并发使用
 	return &simpleRetryInterceptor{
 		config: config,
 	}
@@ -61,14 +61,13 @@ func (interceptor *simpleRetryInterceptor) Intercept(req *http.Request, handler
 		return handler(req)
 	}
 
-	interceptor.config.init()
-
 	// 不重试
 	if interceptor.config.RetryMax <= 0 {
 		return handler(req)
 	}
 
 	// 可能会被重试多次
+	waiter := interceptor.config.newWaiter()
 	for i := 0; ; i++ {
 		// Clone 防止后面 Handler 处理对 req 有污染
 		reqBefore := cloneReq(req.Context(), req)
@@ -83,11 +82,12 @@ func (interceptor *simpleRetryInterceptor) Intercept(req *http.Request, handler
 			break
 		}
 
-		retryInterval := interceptor.config.RetryInterval()
-		if retryInterval < time.Microsecond {
-			continue
+		if ok, sErr := waiter.Wait(req.Context(), resp, err); !ok {
+			break
+		} else if sErr != nil {
+			return nil, sErr
 		}
-		time.Sleep(retryInterval)
+		reportRetry(interceptor.config.Metrics, req)
 	}
 	return resp, err
 }
@@ -131,11 +131,15 @@ func isResponseRetryable(resp *http.Response) bool {
 }
 
 func isStatusCodeRetryable(statusCode int) bool {
+	if isStatusCodeRateLimited(statusCode) {
+		return true
+	}
+
 	if statusCode < 500 {
 		return false
 	}
 
-	if statusCode == 501 || statusCode == 509 || statusCode == 573 || statusCode == 579 ||
+	if statusCode == 501 || statusCode == 509 || statusCode == 579 ||
 		statusCode == 608 || statusCode == 612 || statusCode == 614 || statusCode == 616 || statusCode == 618 ||
 		statusCode == 630 || statusCode == 631 || statusCode == 632 || statusCode == 640 || statusCode == 701 {
 		return false
@@ -159,7 +163,7 @@ func IsErrorRetryable(err error) bool {
 	case *clientv1.ErrorInfo:
 		return isStatusCodeRetryable(t.Code)
 	default:
-		if err == io.EOF {
+		if err == io.EOF || err == ErrCircuitOpen {
 			return true
 		}
 		return false
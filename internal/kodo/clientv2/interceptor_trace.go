@@ -0,0 +1,145 @@
+package clientv2
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Tracer 创建 span，用于对接调用方的链路追踪系统，其方法会被并发调用
+type Tracer interface {
+	// Start 开始名为 name 的 span，ctx 中有 span 时作为其子 span，返回带有新 span 的 context
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span 一次操作（如 Open、Upload）或一次 HTTP 请求（每次重试都是一次请求）
+type Span interface {
+	// SetAttribute 设置 span 的属性，value 为 string、int、bool 或 time.Duration
+	SetAttribute(key string, value interface{})
+
+	// End 结束 span，err 为操作的错误
+	End(err error)
+}
+
+// StartSpan 通过 tracer 开始一个 span，tracer 为 nil 时返回不记录任何数据的 span
+func StartSpan(ctx context.Context, tracer Tracer, name string) (context.Context, Span) {
+	if tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return tracer.Start(ctx, name)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) End(err error)                              {}
+
+// HTTP 请求 span 的名称及属性
+const (
+	SpanHTTPRequest = "kodo.HTTP"
+
+	AttrAPIClass   = "kodo.api_class"
+	AttrMethod     = "http.method"
+	AttrHost       = "http.host"
+	AttrStatusCode = "http.status_code"
+	AttrReqid      = "kodo.reqid"
+	AttrConnReused = "net.conn_reused"
+	AttrDNS        = "net.dns"         // DNS 解析耗时
+	AttrConnect    = "net.connect"     // 建立 tcp 连接耗时
+	AttrTLS        = "net.tls"         // TLS 握手耗时
+	AttrFirstByte  = "http.first_byte" // 请求发送完毕至收到第一个响应字节的耗时
+)
+
+type traceInterceptor struct {
+	tracer Tracer
+}
+
+// NewTraceInterceptor 返回为每次 HTTP 请求创建 span 的拦截器，其位于重试之内，
+// span 中记录 DNS 解析、建立连接、TLS 握手及等待响应各阶段的耗时
+func NewTraceInterceptor(tracer Tracer) Interceptor {
+	return &traceInterceptor{tracer: tracer}
+}
+
+func (interceptor *traceInterceptor) Priority() InterceptorPriority {
+	return InterceptorPriorityTrace
+}
+
+func (interceptor *traceInterceptor) Intercept(req *http.Request, handler Handler) (*http.Response, error) {
+	if interceptor == nil || interceptor.tracer == nil || req == nil {
+		return handler(req)
+	}
+
+	ctx, span := interceptor.tracer.Start(req.Context(), SpanHTTPRequest)
+	if class, ok := APIClassFromContext(ctx); ok {
+		span.SetAttribute(AttrAPIClass, string(class))
+	}
+	span.SetAttribute(AttrMethod, req.Method)
+	span.SetAttribute(AttrHost, req.URL.Host)
+
+	timing := &phaseTiming{}
+	resp, err := handler(req.WithContext(httptrace.WithClientTrace(ctx, timing.clientTrace())))
+	if err == nil && resp != nil {
+		span.SetAttribute(AttrStatusCode, resp.StatusCode)
+		if reqid := resp.Header.Get("X-Reqid"); reqid != "" {
+			span.SetAttribute(AttrReqid, reqid)
+		}
+	}
+	timing.setAttributes(span)
+	span.End(err)
+	return resp, err
+}
+
+// phaseTiming 通过 httptrace 统计请求各阶段的耗时
+type phaseTiming struct {
+	dnsStart, connectStart, tlsStart, wroteRequest time.Time
+	dns, connect, tls, firstByte                   time.Duration
+	connReused                                     bool
+	mutex                                          sync.Mutex // 建立连接的回调可能在其他 goroutine 中执行
+}
+
+func (p *phaseTiming) clientTrace() *httptrace.ClientTrace {
+	since := func(start *time.Time, d *time.Duration) {
+		p.mutex.Lock()
+		if !start.IsZero() {
+			*d = time.Since(*start)
+		}
+		p.mutex.Unlock()
+	}
+	now := func(t *time.Time) {
+		p.mutex.Lock()
+		*t = time.Now()
+		p.mutex.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { now(&p.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { since(&p.dnsStart, &p.dns) },
+		ConnectStart:      func(network, addr string) { now(&p.connectStart) },
+		ConnectDone:       func(network, addr string, err error) { since(&p.connectStart, &p.connect) },
+		TLSHandshakeStart: func() { now(&p.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { since(&p.tlsStart, &p.tls) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			p.mutex.Lock()
+			p.connReused = info.Reused
+			p.mutex.Unlock()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { now(&p.wroteRequest) },
+		GotFirstResponseByte: func() { since(&p.wroteRequest, &p.firstByte) },
+	}
+}
+
+func (p *phaseTiming) setAttributes(span Span) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	span.SetAttribute(AttrConnReused, p.connReused)
+	for _, a := range []struct {
+		key string
+		d   time.Duration
+	}{{AttrDNS, p.dns}, {AttrConnect, p.connect}, {AttrTLS, p.tls}, {AttrFirstByte, p.firstByte}} {
+		if a.d > 0 {
+			span.SetAttribute(a.key, a.d)
+		}
+	}
+}
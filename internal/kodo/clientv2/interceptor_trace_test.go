@@ -0,0 +1,91 @@
+package clientv2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testSpan struct {
+	name   string
+	parent *testSpan
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+func (p *testSpan) SetAttribute(key string, value interface{}) { p.attrs[key] = value }
+func (p *testSpan) End(err error)                              { p.err, p.ended = err, true }
+
+type testSpanKey struct{}
+
+type testTracer struct {
+	spans []*testSpan
+	mutex sync.Mutex
+}
+
+func (p *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(testSpanKey{}).(*testSpan)
+	span := &testSpan{name: name, parent: parent, attrs: make(map[string]interface{})}
+	p.mutex.Lock()
+	p.spans = append(p.spans, span)
+	p.mutex.Unlock()
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+func TestTrace(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Reqid", "server-reqid")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	tracer := &testTracer{}
+	interceptor := NewTraceInterceptor(tracer)
+	ctx, op := StartSpan(WithAPIClass(context.Background(), APIClassRs), tracer, "kodo.Stat")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, ts.URL+"/stat/a", nil)
+	resp, err := interceptor.Intercept(req, http.DefaultTransport.RoundTrip)
+	if err != nil {
+		t.Fatal("Intercept:", err)
+	}
+	resp.Body.Close()
+	errFail := errors.New("connection refused")
+	req, _ = http.NewRequestWithContext(ctx, http.MethodPost, ts.URL+"/stat/a", nil)
+	interceptor.Intercept(req, func(req *http.Request) (*http.Response, error) {
+		return nil, errFail
+	})
+	op.End(nil)
+
+	if len(tracer.spans) != 3 {
+		t.Fatal("spans:", len(tracer.spans))
+	}
+	span := tracer.spans[1]
+	if span.name != SpanHTTPRequest || span.parent != op || !span.ended || span.err != nil {
+		t.Fatal("span:", span.name, span.parent, span.ended, span.err)
+	}
+	host := strings.TrimPrefix(ts.URL, "http://")
+	for key, value := range map[string]interface{}{
+		AttrAPIClass: "rs", AttrMethod: "POST", AttrHost: host, AttrStatusCode: 404, AttrReqid: "server-reqid",
+		AttrConnReused: false,
+	} {
+		if span.attrs[key] != value {
+			t.Errorf("%s: %v", key, span.attrs[key])
+		}
+	}
+	for _, key := range []string{AttrConnect, AttrFirstByte} {
+		if _, ok := span.attrs[key]; !ok {
+			t.Errorf("no %s: %v", key, span.attrs)
+		}
+	}
+	if span = tracer.spans[2]; span.err != errFail || span.attrs[AttrStatusCode] != nil {
+		t.Fatal("failed span:", span.err, span.attrs)
+	}
+
+	if ctx2, s := StartSpan(ctx, nil, "kodo.Stat"); ctx2 != ctx || s == nil {
+		t.Fatal("StartSpan: nil tracer")
+	}
+}
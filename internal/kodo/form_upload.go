@@ -17,7 +17,6 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/xushiwei/kodofs/internal/kodo/api"
@@ -25,6 +24,7 @@ import (
 	"github.com/xushiwei/kodofs/internal/kodo/client"
 	"github.com/xushiwei/kodofs/internal/kodo/clientv2"
 	"github.com/xushiwei/kodofs/internal/kodo/hostprovider"
+	"github.com/xushiwei/kodofs/internal/kodo/log"
 	"golang.org/x/sync/singleflight"
 )
 
@@ -55,6 +55,12 @@ type Region struct {
 
 	// 源站下载入口
 	IoSrcHost string `json:"io_src,omitempty"`
+
+	// 各服务的全部域名（主域名在前，备用域名在后），请求失败时依次切换；为空时只使用上面的单个域名
+	RsHosts    []string `json:"rs_hosts,omitempty"`
+	RsfHosts   []string `json:"rsf_hosts,omitempty"`
+	ApiHosts   []string `json:"api_hosts,omitempty"`
+	IovipHosts []string `json:"io_hosts,omitempty"`
 }
 
 // 获取io host
@@ -62,6 +68,11 @@ func (r *Region) GetIoHost(useHttps bool) string {
 	return endpoint(useHttps, r.IovipHost)
 }
 
+// 获取rsHost
+func (r *Region) GetRsHost(useHttps bool) string {
+	return endpoint(useHttps, r.RsHost)
+}
+
 // 获取rsfHost
 func (r *Region) GetRsfHost(useHttps bool) string {
 	return endpoint(useHttps, r.RsfHost)
@@ -81,6 +92,31 @@ type Config struct {
 	UseCdnDomains bool   //是否使用cdn加速域名
 	CentralRsHost string //中心机房的RsHost，用于list bucket
 
+	// 查询空间相关域名的 uc 服务地址，不指定 scheme 时默认使用 https，为空时使用公有云的默认地址
+	UcHosts []string
+
+	// 额外的拦截器（如限流），用于 BucketManager 及 FormUploader 的请求
+	Interceptors []clientv2.Interceptor
+
+	// 可选，收集 BucketManager、FormUploader 及 uc 查询的请求指标
+	Metrics clientv2.Metrics
+
+	// 可选，为 BucketManager、FormUploader 的每次请求及 uc 查询创建 span
+	Tracer clientv2.Tracer
+
+	// 可选，记录 BucketManager、FormUploader 的每次请求及 uc 查询，其中的 Authorization 头、上传凭证及 AK 被隐去
+	Logger log.FieldLogger
+
+	// 可选，缓存 uc 查询到的区域，为 nil 时使用 DefaultRegionCache；空间不存在或域名无法连接时缓存的区域失效
+	RegionCache RegionCache
+
+	// 可选，区域的缓存时间，为 0 时使用 uc 服务返回的 ttl
+	RegionTTL time.Duration
+
+	// 可选，各服务域名的选择策略（如轮询、按延迟选择），为 nil 时总是选择第一个可用域名；
+	// 其 Available 需包含熔断等域名过滤条件，可被多个 BucketManager 及 FormUploader 共用
+	HostProviders *hostprovider.Cache
+
 	// 兼容保留
 	RsHost  string
 	RsfHost string
@@ -89,6 +125,21 @@ type Config struct {
 	IoHost  string
 }
 
+// interceptors 返回 Interceptors 及收集指标、创建 span、记录日志的拦截器
+func (c *Config) interceptors() []clientv2.Interceptor {
+	is := c.Interceptors[:len(c.Interceptors):len(c.Interceptors)] // 追加时不修改 c.Interceptors
+	if c.Metrics != nil {
+		is = append(is, clientv2.NewMetricsInterceptor(c.Metrics))
+	}
+	if c.Tracer != nil {
+		is = append(is, clientv2.NewTraceInterceptor(c.Tracer))
+	}
+	if c.Logger != nil {
+		is = append(is, clientv2.NewLogInterceptor(c.Logger))
+	}
+	return is
+}
+
 // GetRegion返回一个Region指针
 // 默认返回最新的Region， 如果该字段没有，那么返回兼容保留的Zone, 如果都为nil, 就返回nil
 func (c *Config) GetRegion() *Region {
@@ -153,6 +204,9 @@ type PutExtra struct {
 	// 主备域名冻结时间（默认：600s），当一个域名请求失败（单个域名会被重试 TryTimes 次），会被冻结一段时间，使用备用域名进行重试，在冻结时间内，域名不能被使用，当一个操作中所有域名竣备冻结操作不在进行重试，返回最后一次操作的错误。
 	HostFreezeDuration time.Duration
 
+	// 可选，重试退避策略，为 nil 时使用 clientv2.DefaultBackoff()
+	Backoff *clientv2.Backoff
+
 	// 可选，当为 "" 时候，服务端自动判断。
 	MimeType string
 
@@ -475,15 +529,20 @@ func (p *FormUploader) putSeekableData(ctx context.Context, ret interface{}, upT
 	contentType := formWriter.FormDataContentType()
 	headers := http.Header{}
 	headers.Add("Content-Type", contentType)
-	err = doUploadAction(hostProvider, extra.TryTimes, extra.HostFreezeDuration, func(host string) error {
+	err = doUploadAction(ctx, hostProvider, extra.TryTimes, extra.HostFreezeDuration, extra.Backoff, p.Cfg.Metrics, func(host string) error {
 		reader, gErr := getBodyReader()
 		if gErr != nil {
 			return gErr
 		}
 
-		return p.Client.CallWithBodyGetter(ctx, ret, "POST", host, headers, reader, getBodyReadCloser, formBodyLen)
+		return p.post(ctx, ret, host, headers, reader, getBodyReadCloser, formBodyLen)
 	})
 	if err != nil {
+		if extra.UpHost == "" {
+			if ak, bucket, tErr := getAkBucketFromUploadToken(upToken); tErr == nil {
+				p.Cfg.invalidateRegion(ak, bucket, err)
+			}
+		}
 		return err
 	}
 	if extra.OnProgress != nil {
@@ -492,23 +551,58 @@ func (p *FormUploader) putSeekableData(ctx context.Context, ret interface{}, upT
 	return nil
 }
 
+// post 发送上传请求，请求经过 Cfg.Interceptors
+func (p *FormUploader) post(ctx context.Context, ret interface{}, host string, headers http.Header, body io.Reader,
+	getBody func() (io.ReadCloser, error), bodyLength int64) error {
+	req, err := http.NewRequestWithContext(clientv2.WithAPIClass(ctx, clientv2.APIClassUpload), "POST", host, body)
+	if err != nil {
+		return err
+	}
+	req.Header = headers.Clone()
+	req.ContentLength = bodyLength
+	req.GetBody = getBody
+	c := clientv2.NewClient(clientv2.NewClientWithClientV1(p.Client), p.Cfg.interceptors()...)
+	resp, err := c.Do(req)
+	if resp != nil {
+		defer func() {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	if err != nil {
+		return err
+	}
+	if ret == nil || resp.ContentLength == 0 {
+		return nil
+	}
+	return client.DecodeJsonFromReader(resp.Body, ret)
+}
+
 func (p *FormUploader) getUpHostProviderFromUploadToken(upToken string, extra *PutExtra) (hostprovider.HostProvider, error) {
 	ak, bucket, err := getAkBucketFromUploadToken(upToken)
 	if err != nil {
 		return nil, err
 	}
-	return getUpHostProvider(p.Cfg, extra.TryTimes, extra.HostFreezeDuration, ak, bucket)
+	return getUpHostProvider(p.Cfg, p.Client, extra.TryTimes, extra.HostFreezeDuration, ak, bucket)
 }
 
 // retryMax: 为 0，使用默认值，每个域名只请求一次
 // hostFreezeDuration: 为 0，使用默认值：50ms ~ 100ms
-func getUpHostProvider(config *Config, retryMax int, hostFreezeDuration time.Duration, ak, bucket string) (hostprovider.HostProvider, error) {
+// clt: 查询 uc 服务所用的 client，为 nil 时使用 client.DefaultClient
+func getUpHostProvider(config *Config, clt *client.Client, retryMax int, hostFreezeDuration time.Duration, ak, bucket string) (hostprovider.HostProvider, error) {
 	region := config.GetRegion()
 	var err error
 	if region == nil {
 		if region, err = GetRegionWithOptions(ak, bucket, UCApiOptions{
 			RetryMax:           retryMax,
 			HostFreezeDuration: hostFreezeDuration,
+			Hosts:              config.UcHosts,
+			Client:             clt,
+			Metrics:            config.Metrics,
+			Tracer:             config.Tracer,
+			Logger:             config.Logger,
+			RegionCache:        config.RegionCache,
+			RegionTTL:          config.RegionTTL,
 		}); err != nil {
 			return nil, err
 		}
@@ -525,6 +619,9 @@ func getUpHostProvider(config *Config, retryMax int, hostFreezeDuration time.Dur
 		hosts[i] = endpoint(config.UseHTTPS, hosts[i])
 	}
 
+	if config.HostProviders != nil {
+		return config.HostProviders.Get(hosts), nil
+	}
 	return hostprovider.NewWithHosts(hosts), nil
 }
 
@@ -557,10 +654,23 @@ func getAkBucketFromUploadToken(token string) (ak, bucket string, err error) {
 }
 
 type UCApiOptions struct {
-	UseHttps bool //
-	RetryMax int  // 单域名重试次数
+	UseHttps bool     //
+	RetryMax int      // 单域名重试次数
+	Hosts    []string // uc 服务地址，为空时使用 UcHost 或公有云的默认地址
 	// 主备域名冻结时间（默认：600s），当一个域名请求失败（单个域名会被重试 TryTimes 次），会被冻结一段时间，使用备用域名进行重试，在冻结时间内，域名不能被使用，当一个操作中所有域名竣备冻结操作不在进行重试，返回最后一次操作的错误。
 	HostFreezeDuration time.Duration
+	// 请求 uc 服务所用的 client，为 nil 时使用 client.DefaultClient
+	Client *client.Client
+	// 可选，收集 uc 查询的请求指标
+	Metrics clientv2.Metrics
+	// 可选，为 uc 查询创建 span
+	Tracer clientv2.Tracer
+	// 可选，记录 uc 查询
+	Logger log.FieldLogger
+	// 可选，缓存查询到的区域，为 nil 时使用 DefaultRegionCache
+	RegionCache RegionCache
+	// 可选，区域的缓存时间，为 0 时使用 uc 服务返回的 ttl
+	RegionTTL time.Duration
 }
 
 // 此处废弃，但为了兼容老版本，单独放置一个文件
@@ -587,6 +697,16 @@ type UcQueryRet struct {
 	ApiInfo   map[string]UcQueryServerInfo   `json:"api"`
 }
 
+// getHostsFromInfo 返回全部域名，src 在前，acc 在后，主域名在前，备用域名在后
+func (uc *UcQueryRet) getHostsFromInfo(info map[string]UcQueryIo) []string {
+	var hosts []string
+	for _, kind := range []string{"src", "acc"} {
+		hosts = append(hosts, info[kind].Main...)
+		hosts = append(hosts, info[kind].Backup...)
+	}
+	return removeRepeatStringItem(hosts)
+}
+
 func (uc *UcQueryRet) getOneHostFromInfo(info map[string]UcQueryIo) string {
 	if len(info["src"].Main) > 0 {
 		return info["src"].Main[0]
@@ -601,61 +721,6 @@ func (uc *UcQueryRet) getOneHostFromInfo(info map[string]UcQueryIo) string {
 
 var ucQueryV2Group singleflight.Group
 
-type regionV2CacheValue struct {
-	Region   *Region   `json:"region"`
-	Deadline time.Time `json:"deadline"`
-}
-
-type regionV2CacheMap map[string]regionV2CacheValue
-
-const regionV2CacheFileName = "query_v2_00.cache.json"
-
-var (
-	regionV2CachePath     = filepath.Join(os.TempDir(), "qiniu-golang-sdk", regionV2CacheFileName)
-	regionV2Cache         sync.Map
-	regionV2CacheLock     sync.RWMutex
-	regionV2CacheSyncLock sync.Mutex
-	regionV2CacheLoaded   bool = false
-)
-
-func loadRegionV2Cache() {
-	cacheFile, err := os.Open(regionV2CachePath)
-	if err != nil {
-		return
-	}
-	defer cacheFile.Close()
-
-	var cacheMap regionV2CacheMap
-	if err = json.NewDecoder(cacheFile).Decode(&cacheMap); err != nil {
-		return
-	}
-	for cacheKey, cacheValue := range cacheMap {
-		regionV2Cache.Store(cacheKey, cacheValue)
-	}
-}
-
-func storeRegionV2Cache() {
-	err := os.MkdirAll(filepath.Dir(regionV2CachePath), 0700)
-	if err != nil {
-		return
-	}
-
-	cacheFile, err := os.OpenFile(regionV2CachePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
-	if err != nil {
-		return
-	}
-	defer cacheFile.Close()
-
-	cacheMap := make(regionV2CacheMap)
-	regionV2Cache.Range(func(cacheKey, cacheValue interface{}) bool {
-		cacheMap[cacheKey.(string)] = cacheValue.(regionV2CacheValue)
-		return true
-	})
-	if err = json.NewEncoder(cacheFile).Encode(cacheMap); err != nil {
-		return
-	}
-}
-
 const (
 	defaultApiHost = "api.qiniu.com"
 	defaultUcHost0 = "kodo-config.qiniuapi.com"
@@ -664,17 +729,19 @@ const (
 
 // UcHost 为查询空间相关域名的 API 服务地址
 // 设置 UcHost 时，如果不指定 scheme 默认会使用 https
-// Deprecated 使用 SetUcHosts 替换
+// Deprecated 使用 Config.UcHosts 或 UCApiOptions.Hosts 按 client 指定，无需修改全局变量
 var UcHost = ""
 
 // 公有云包括 defaultApiHost，非 uc query api 使用时需要移除 defaultApiHost
 // 用户配置时，不能配置 api 域名
 var ucHosts = []string{defaultUcHost0, defaultUcHost1, defaultApiHost}
 
-func getUcHost(useHttps bool) string {
+func getUcHost(hosts []string, useHttps bool) string {
 	// 兼容老版本，优先使用 UcHost
 	host := ""
-	if len(UcHost) > 0 {
+	if len(hosts) > 0 {
+		host = hosts[0]
+	} else if len(UcHost) > 0 {
 		host = UcHost
 	} else if len(ucHosts) > 0 {
 		host = ucHosts[0]
@@ -692,12 +759,26 @@ type ucClientConfig struct {
 	// 主备域名冻结时间（默认：600s），当一个域名请求失败（单个域名会被重试 TryTimes 次），会被冻结一段时间，使用备用域名进行重试，在冻结时间内，域名不能被使用，当一个操作中所有域名竣备冻结操作不在进行重试，返回最后一次操作的错误。
 	HostFreezeDuration time.Duration
 
+	// uc 服务地址，为空时使用 UcHost 或公有云的默认地址
+	Hosts []string
+
 	Client *client.Client
+
+	Metrics clientv2.Metrics
+	Tracer  clientv2.Tracer
+	Logger  log.FieldLogger
 }
 
 // 不带 scheme
-func getUcBackupHosts() []string {
+func getUcBackupHosts(custom []string) []string {
 	var hosts []string
+	if len(custom) > 0 {
+		for _, host := range custom {
+			hosts = append(hosts, removeHostScheme(host))
+		}
+		return removeRepeatStringItem(hosts)
+	}
+
 	if len(UcHost) > 0 {
 		hosts = append(hosts, removeHostScheme(UcHost))
 	}
@@ -732,7 +813,7 @@ func removeHostScheme(host string) string {
 }
 
 func getUCClient(config ucClientConfig, mac *auth.Credentials) clientv2.Client {
-	allHosts := getUcBackupHosts()
+	allHosts := getUcBackupHosts(config.Hosts)
 	var hosts []string = nil
 	if !config.IsUcQueryApi {
 		// 非 uc query api 去除 defaultApiHost
@@ -751,6 +832,7 @@ func getUCClient(config ucClientConfig, mac *auth.Credentials) clientv2.Client {
 				RetryMax:      len(hosts),
 				RetryInterval: nil,
 				ShouldRetry:   nil,
+				Metrics:       config.Metrics,
 			},
 			ShouldFreezeHost:   nil,
 			HostFreezeDuration: 0,
@@ -760,9 +842,20 @@ func getUCClient(config ucClientConfig, mac *auth.Credentials) clientv2.Client {
 			RetryMax:      config.RetryMax,
 			RetryInterval: nil,
 			ShouldRetry:   nil,
+			Metrics:       config.Metrics,
 		}),
 	}
 
+	if config.Metrics != nil {
+		is = append(is, clientv2.NewMetricsInterceptor(config.Metrics))
+	}
+	if config.Tracer != nil {
+		is = append(is, clientv2.NewTraceInterceptor(config.Tracer))
+	}
+	if config.Logger != nil {
+		is = append(is, clientv2.NewLogInterceptor(config.Logger))
+	}
+
 	if mac != nil {
 		is = append(is, clientv2.NewAuthInterceptor(clientv2.AuthConfig{
 			Credentials: *mac,
@@ -774,47 +867,39 @@ func getUCClient(config ucClientConfig, mac *auth.Credentials) clientv2.Client {
 }
 
 func getRegionByV2(ak, bucket string, options UCApiOptions) (*Region, error) {
-
-	regionV2CacheLock.RLock()
-	if regionV2CacheLoaded {
-		regionV2CacheLock.RUnlock()
-	} else {
-		regionV2CacheLock.RUnlock()
-		func() {
-			regionV2CacheLock.Lock()
-			defer regionV2CacheLock.Unlock()
-
-			if !regionV2CacheLoaded {
-				loadRegionV2Cache()
-				regionV2CacheLoaded = true
-			}
-		}()
-	}
-
-	regionID := fmt.Sprintf("%s:%s", ak, bucket)
+	cache := options.regionCache()
+	regionID := regionCacheKey(ak, bucket, &options)
 	//check from cache
-	if v, ok := regionV2Cache.Load(regionID); ok && time.Now().Before(v.(regionV2CacheValue).Deadline) {
-		return v.(regionV2CacheValue).Region, nil
+	if region, ok := cache.Load(regionID); ok {
+		return region, nil
 	}
 
 	newRegion, err, _ := ucQueryV2Group.Do(regionID, func() (interface{}, error) {
-		reqURL := fmt.Sprintf("%s/v2/query?ak=%s&bucket=%s", getUcHost(options.UseHttps), ak, bucket)
+		reqURL := fmt.Sprintf("%s/v2/query?ak=%s&bucket=%s", getUcHost(options.Hosts, options.UseHttps), ak, bucket)
 
 		var ret UcQueryRet
 		c := getUCClient(ucClientConfig{
 			IsUcQueryApi:       true,
 			RetryMax:           options.RetryMax,
 			HostFreezeDuration: options.HostFreezeDuration,
+			Hosts:              options.Hosts,
+			Client:             options.Client,
+			Metrics:            options.Metrics,
+			Tracer:             options.Tracer,
+			Logger:             options.Logger,
 		}, nil)
+		ctx, span := clientv2.StartSpan(context.Background(), options.Tracer, "kodo.QueryRegion")
+		span.SetAttribute("kodo.bucket", bucket)
 		_, err := clientv2.DoAndDecodeJsonResponse(c, clientv2.RequestParams{
-			Context:     context.Background(),
+			Context:     clientv2.WithAPIClass(ctx, clientv2.APIClassUc),
 			Method:      clientv2.RequestMethodGet,
 			Url:         reqURL,
 			Header:      nil,
 			BodyCreator: nil,
 		}, &ret)
+		span.End(err)
 		if err != nil {
-			return nil, fmt.Errorf("query region error, %s", err.Error())
+			return nil, fmt.Errorf("query region error, %w", err)
 		}
 
 		ioHost := ret.getOneHostFromInfo(ret.IoInfo)
@@ -859,17 +944,17 @@ func getRegionByV2(ak, bucket string, options UCApiOptions) (*Region, error) {
 			RsfHost:    rsfHost,
 			ApiHost:    apiHost,
 			IoSrcHost:  ioSrcHost,
+			RsHosts:    ret.getHostsFromInfo(ret.RsInfo),
+			RsfHosts:   ret.getHostsFromInfo(ret.RsfInfo),
+			ApiHosts:   ret.getHostsFromInfo(ret.ApiInfo),
+			IovipHosts: ret.getHostsFromInfo(ret.IoInfo),
 		}
 
-		regionV2Cache.Store(regionID, regionV2CacheValue{
-			Region:   region,
-			Deadline: time.Now().Add(time.Duration(ret.TTL) * time.Second),
-		})
-
-		regionV2CacheSyncLock.Lock()
-		defer regionV2CacheSyncLock.Unlock()
-
-		storeRegionV2Cache()
+		ttl := time.Duration(ret.TTL) * time.Second
+		if options.RegionTTL > 0 {
+			ttl = options.RegionTTL
+		}
+		cache.Store(regionID, region, ttl)
 		return region, nil
 	})
 	if newRegion == nil {
@@ -913,18 +998,27 @@ func shouldUploadRetryWithOtherHost(err error) bool {
 	return clientv2.IsErrorRetryable(err)
 }
 
-func doUploadAction(hostProvider hostprovider.HostProvider, retryMax int, freezeDuration time.Duration, action func(host string) error) error {
-	for {
+func doUploadAction(ctx context.Context, hostProvider hostprovider.HostProvider, retryMax int, freezeDuration time.Duration,
+	backoff *clientv2.Backoff, metrics clientv2.Metrics, action func(host string) error) error {
+	waiter := backoff.NewWaiter()
+	for limited := 0; ; {
 		host, err := hostProvider.Provider()
 		if err != nil {
-			return api.NewError(ErrMaxUpRetry, err.Error())
+			return api.WrapError(ErrMaxUpRetry, err)
 		}
 
 		for i := 0; ; i++ {
+			start := time.Now()
 			err = action(host)
+			if r, ok := hostProvider.(hostprovider.LatencyRecorder); ok {
+				r.Record(host, time.Since(start), err)
+			}
 
-			// 请求成功
+			// 请求成功，域名恢复可用
 			if err == nil {
+				if u, ok := hostProvider.(hostprovider.Unfreezer); ok {
+					_ = u.Unfreeze(host)
+				}
 				return nil
 			}
 
@@ -937,10 +1031,36 @@ func doUploadAction(hostProvider hostprovider.HostProvider, retryMax int, freeze
 			if i >= retryMax {
 				break
 			}
+
+			// 退避等待，超过总预算时不再重试
+			if ok, sErr := waiter.Wait(ctx, nil, err); !ok {
+				return err
+			} else if sErr != nil {
+				return sErr
+			}
+			if metrics != nil {
+				metrics.Retry(clientv2.APIClassUpload, removeHostScheme(host))
+			}
+		}
+
+		// 限流不是域名故障，不冻结此 host，退避等待后重新选择 host；由于此 host 未被冻结，
+		// 可能再次被选中，因此限制重新选择的次数
+		if clientv2.IsRateLimited(nil, err) {
+			if limited++; limited > retryMax {
+				return err
+			}
+			if ok, sErr := waiter.Wait(ctx, nil, err); !ok {
+				return err
+			} else if sErr != nil {
+				return sErr
+			}
+			continue
 		}
 
 		// 单个 host 失败，冻结此 host，换其他 host
-		_ = hostProvider.Freeze(host, err, freezeDuration)
+		if fErr := hostProvider.Freeze(host, err, freezeDuration); fErr == nil && metrics != nil {
+			metrics.HostFrozen(clientv2.APIClassUpload, removeHostScheme(host))
+		}
 	}
 }
 
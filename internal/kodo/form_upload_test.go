@@ -0,0 +1,92 @@
+package kodo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xushiwei/kodofs/internal/kodo/client"
+	"github.com/xushiwei/kodofs/internal/kodo/clientv2"
+)
+
+// stubHostProvider 轮流返回 hosts，并记录被冻结的 host
+type stubHostProvider struct {
+	hosts  []string
+	next   int
+	frozen []string
+}
+
+func (p *stubHostProvider) Provider() (string, error) {
+	host := p.hosts[p.next%len(p.hosts)]
+	p.next++
+	return host, nil
+}
+
+func (p *stubHostProvider) Freeze(host string, cause error, duration time.Duration) error {
+	p.frozen = append(p.frozen, host)
+	return nil
+}
+
+// frozenMetrics 记录被冻结的 host
+type frozenMetrics struct {
+	frozen []string
+}
+
+func (p *frozenMetrics) Request(class clientv2.APIClass, host string, code int, latency time.Duration) {
+}
+func (p *frozenMetrics) Retry(class clientv2.APIClass, host string)                  {}
+func (p *frozenMetrics) BytesSent(class clientv2.APIClass, host string, n int64)     {}
+func (p *frozenMetrics) BytesReceived(class clientv2.APIClass, host string, n int64) {}
+
+func (p *frozenMetrics) HostFrozen(class clientv2.APIClass, host string) {
+	p.frozen = append(p.frozen, host)
+}
+
+func TestDoUploadActionFreeze(t *testing.T) {
+	backoff := &clientv2.Backoff{Base: time.Millisecond, RateLimited: time.Millisecond}
+	for _, code := range []int{573, 429, 502} {
+		hp := &stubHostProvider{hosts: []string{"up-a", "up-b"}}
+		metrics := &frozenMetrics{}
+		var used []string
+		err := doUploadAction(context.Background(), hp, 1, time.Minute, backoff, metrics, func(host string) error {
+			used = append(used, host)
+			if host == "up-a" {
+				return &client.ErrorInfo{Code: code}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(code, "doUploadAction:", err)
+		}
+		if len(used) != 3 || used[2] != "up-b" {
+			t.Fatal(code, "hosts:", used)
+		}
+		rateLimited := code != 502
+		if rateLimited != (len(hp.frozen) == 0) || len(metrics.frozen) != len(hp.frozen) {
+			t.Fatal(code, "frozen:", hp.frozen, metrics.frozen)
+		}
+	}
+
+	// 所有 host 都限流时，换 host 的次数有限
+	hp := &stubHostProvider{hosts: []string{"up-a"}}
+	n := 0
+	err := doUploadAction(context.Background(), hp, 1, time.Minute, backoff, nil, func(host string) error {
+		n++
+		return &client.ErrorInfo{Code: 573}
+	})
+	if !clientv2.IsRateLimited(nil, err) || n != 4 || len(hp.frozen) != 0 {
+		t.Fatal("doUploadAction:", err, n, hp.frozen)
+	}
+
+	// 重新选择 host 前也要退避等待：预算只够等待两次，第三次请求失败后放弃
+	hp = &stubHostProvider{hosts: []string{"up-a"}}
+	n = 0
+	budget := &clientv2.Backoff{Base: time.Millisecond, RateLimited: 10 * time.Millisecond, Budget: 25 * time.Millisecond}
+	err = doUploadAction(context.Background(), hp, 1, time.Minute, budget, nil, func(host string) error {
+		n++
+		return &client.ErrorInfo{Code: 573}
+	})
+	if !clientv2.IsRateLimited(nil, err) || n != 3 {
+		t.Fatal("doUploadAction:", err, n)
+	}
+}
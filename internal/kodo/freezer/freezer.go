@@ -1,6 +1,7 @@
 package freezer
 
 import (
+	"sort"
 	"sync"
 	"time"
 )
@@ -9,40 +10,98 @@ type Freezer interface {
 	Available(itemId string) bool
 	Freeze(itemId string, duration time.Duration) error
 	Unfreeze(itemId string) error
+
+	// Frozen 返回冻结中的条目及其解冻时间，按解冻时间排序
+	Frozen() []FrozenItem
+}
+
+// FrozenItem 冻结中的条目
+type FrozenItem struct {
+	ItemId string
+	Until  time.Time // 解冻时间
 }
 
+// Clock 提供当前时间，用于在测试中控制冻结的时间
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock 返回系统时间
+var SystemClock Clock = systemClock{}
+
 func New() Freezer {
+	return NewWithClock(nil)
+}
+
+// NewWithClock 返回使用 clock 计时的 Freezer，clock 为 nil 时使用 SystemClock
+func NewWithClock(clock Clock) Freezer {
+	if clock == nil {
+		clock = SystemClock
+	}
 	return &freezer{
-		freezerItems: &sync.Map{},
+		clock:        clock,
+		freezerItems: make(map[string]time.Time),
 	}
 }
 
 type freezer struct {
-	freezerItems *sync.Map
+	clock        Clock
+	freezerItems map[string]time.Time // itemId => 解冻时间
+	mutex        sync.Mutex
 }
 
 func (i *freezer) Available(itemId string) bool {
-	unfreezeTime, ok := i.freezerItems.Load(itemId)
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	unfreezeTime, ok := i.freezerItems[itemId]
 	if !ok {
 		return true
 	}
-
-	unfreezeTimeInt64, ok := unfreezeTime.(int64)
-	if !ok {
+	if i.clock.Now().Before(unfreezeTime) {
 		return false
 	}
-
-	timestamp := time.Now().Unix()
-	return timestamp > unfreezeTimeInt64
+	delete(i.freezerItems, itemId)
+	return true
 }
 
 func (i *freezer) Freeze(itemId string, duration time.Duration) error {
-	timestamp := time.Now().Unix()
-	i.freezerItems.Store(itemId, timestamp+int64(duration/time.Second))
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.freezerItems[itemId] = i.clock.Now().Add(duration)
 	return nil
 }
 
 func (i *freezer) Unfreeze(itemId string) error {
-	i.freezerItems.Delete(itemId)
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	delete(i.freezerItems, itemId)
 	return nil
 }
+
+func (i *freezer) Frozen() []FrozenItem {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	now := i.clock.Now()
+	items := make([]FrozenItem, 0, len(i.freezerItems))
+	for itemId, unfreezeTime := range i.freezerItems {
+		if now.Before(unfreezeTime) {
+			items = append(items, FrozenItem{ItemId: itemId, Until: unfreezeTime})
+		} else {
+			delete(i.freezerItems, itemId)
+		}
+	}
+	sort.Slice(items, func(a, b int) bool {
+		return items[a].Until.Before(items[b].Until)
+	})
+	return items
+}
@@ -0,0 +1,101 @@
+package freezer
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now   time.Time
+	mutex sync.Mutex
+}
+
+func (p *fakeClock) Now() time.Time {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.now
+}
+
+func (p *fakeClock) Add(d time.Duration) {
+	p.mutex.Lock()
+	p.now = p.now.Add(d)
+	p.mutex.Unlock()
+}
+
+func TestFreezer(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	f := NewWithClock(clock)
+	if !f.Available("a") {
+		t.Fatal("Available: not frozen")
+	}
+
+	f.Freeze("a", 300*time.Millisecond) // shorter than a second
+	if f.Available("a") {
+		t.Fatal("Available: frozen")
+	}
+	clock.Add(299 * time.Millisecond)
+	if f.Available("a") {
+		t.Fatal("Available: frozen for 299ms")
+	}
+	clock.Add(time.Millisecond)
+	if !f.Available("a") {
+		t.Fatal("Available: thawed")
+	}
+
+	f.Freeze("a", time.Minute)
+	clock.Add(time.Second)
+	f.Freeze("b", time.Second)
+	frozen := f.Frozen()
+	if len(frozen) != 2 || frozen[0].ItemId != "b" || !frozen[1].Until.Equal(clock.Now().Add(59*time.Second)) {
+		t.Fatal("Frozen:", frozen)
+	}
+	f.Unfreeze("a") // eg. a request to the frozen host succeeds
+	if !f.Available("a") {
+		t.Fatal("Available: unfrozen")
+	}
+	clock.Add(time.Second)
+	if frozen = f.Frozen(); len(frozen) != 0 {
+		t.Fatal("Frozen: thawed", frozen)
+	}
+}
+
+func TestSystemClock(t *testing.T) {
+	f := New()
+	f.Freeze("a", 50*time.Millisecond)
+	if f.Available("a") {
+		t.Fatal("Available: frozen")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !f.Available("a") {
+		t.Fatal("Available: thawed")
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	f := NewWithClock(clock)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := strconv.Itoa(i % 2)
+			for j := 0; j < 1000; j++ {
+				switch j % 4 {
+				case 0:
+					f.Freeze(id, time.Millisecond)
+				case 1:
+					f.Available(id)
+				case 2:
+					f.Frozen()
+				case 3:
+					f.Unfreeze(id)
+					clock.Add(time.Millisecond)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -2,6 +2,10 @@ package hostprovider
 
 import (
 	"errors"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/xushiwei/kodofs/internal/kodo/freezer"
@@ -12,17 +16,135 @@ type HostProvider interface {
 	Freeze(host string, cause error, duration time.Duration) error
 }
 
+// LatencyRecorder 由根据请求延迟选择域名的 HostProvider 实现，每次请求结束后调用
+type LatencyRecorder interface {
+	Record(host string, latency time.Duration, err error)
+}
+
+// Unfreezer 由可解冻域名的 HostProvider 实现，请求成功后调用，使冻结中（如所有域名都被冻结时仍被使用）的域名恢复可用
+type Unfreezer interface {
+	Unfreeze(host string) error
+}
+
+// Inspector 由可查询冻结状态的 HostProvider 实现，用于调试
+type Inspector interface {
+	Frozen() []FrozenHost
+}
+
+// FrozenHost 冻结中的域名
+type FrozenHost struct {
+	Host  string
+	Until time.Time // 解冻时间
+	Cause error     // 冻结的原因
+}
+
+// Strategy 从可用域名中选择域名的策略
+type Strategy int
+
+const (
+	StrategyFirst      Strategy = iota // 总是选择第一个可用域名，失败后才切换（默认）
+	StrategyRoundRobin                 // 轮流选择可用域名
+	StrategyEWMA                       // 按请求延迟的指数加权移动平均值加权随机选择，延迟越低被选中的概率越高
+)
+
+type Options struct {
+	Strategy Strategy
+
+	// 可选，跳过 Available 返回 false 的域名（如熔断中的域名），以不带 scheme 的域名调用
+	Available func(host string) bool
+
+	// 可选，跳过健康检查失败的域名，以带 scheme 的域名检查，使其按 scheme 连接对应的端口
+	Prober *Prober
+
+	// StrategyEWMA 的衰减系数，取值 (0, 1]，越大越偏重最近的延迟（默认：0.3）
+	EWMADecay float64
+
+	// 可选，域名冻结的计时时钟，默认使用 freezer.SystemClock
+	Clock freezer.Clock
+}
+
 func NewWithHosts(hosts []string) HostProvider {
-	return &arrayHostProvider{
+	return New(hosts, Options{})
+}
+
+// NewWithHostsFilter 返回的 HostProvider 跳过 available 返回 false 的域名（如熔断中的域名）
+func NewWithHostsFilter(hosts []string, available func(host string) bool) HostProvider {
+	return New(hosts, Options{Available: available})
+}
+
+// New 返回按 opts.Strategy 选择域名的 HostProvider。hosts 可带 scheme，Provider 原样返回；
+// 冻结、解冻、延迟统计及 opts.Available 都以去除 scheme 的域名为准，因此调用方可传入任一形式的域名
+func New(hosts []string, opts Options) HostProvider {
+	if opts.EWMADecay <= 0 || opts.EWMADecay > 1 {
+		opts.EWMADecay = 0.3
+	}
+	a := &arrayHostProvider{
 		hosts:   hosts,
-		freezer: freezer.New(),
+		opts:    opts,
+		freezer: freezer.NewWithClock(opts.Clock),
+		causes:  make(map[string]error),
+	}
+	if opts.Strategy == StrategyEWMA {
+		a.ewma = make(map[string]float64)
+	}
+	return a
+}
+
+// Cache 按域名列表缓存 HostProvider，使同一组域名的多次请求共用域名的冻结状态及延迟统计
+type Cache struct {
+	opts      Options
+	providers sync.Map // 域名列表 => HostProvider
+}
+
+// NewCache 返回以 opts 创建 HostProvider 的 Cache，可被多个 client 共用
+func NewCache(opts Options) *Cache {
+	return &Cache{opts: opts}
+}
+
+// Get 返回域名列表 hosts 的 HostProvider，不存在时创建
+func (c *Cache) Get(hosts []string) HostProvider {
+	key := strings.Join(hosts, ",")
+	if hp, ok := c.providers.Load(key); ok {
+		return hp.(HostProvider)
+	}
+	hp, _ := c.providers.LoadOrStore(key, New(hosts, c.opts))
+	return hp.(HostProvider)
+}
+
+// Frozen 返回所有 HostProvider 中冻结中的域名，按解冻时间排序
+func (c *Cache) Frozen() []FrozenHost {
+	var ret []FrozenHost
+	c.providers.Range(func(_, hp interface{}) bool {
+		if i, ok := hp.(Inspector); ok {
+			ret = append(ret, i.Frozen()...)
+		}
+		return true
+	})
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Until.Before(ret[j].Until)
+	})
+	return ret
+}
+
+// Other 返回 host 以外的一个可用域名，用于对冲请求
+func Other(hp HostProvider, host string) (string, error) {
+	if a, ok := hp.(*arrayHostProvider); ok {
+		return a.pick(host)
 	}
+	return "", errNoOtherHost
 }
 
+var errNoOtherHost = errors.New("no other host available")
+
 type arrayHostProvider struct {
-	hosts         []string
+	hosts         []string // 可带 scheme
+	opts          Options
 	freezer       freezer.Freezer
 	lastFreezeErr error
+	causes        map[string]error   // 各冻结域名的冻结原因（以 hostKey 为 key，下同）
+	next          int                // StrategyRoundRobin 下次开始选择的位置
+	ewma          map[string]float64 // StrategyEWMA 各域名的平均延迟（秒）
+	mutex         sync.Mutex         // HostProvider 可被多个请求并发使用
 }
 
 func (a *arrayHostProvider) Provider() (string, error) {
@@ -30,24 +152,132 @@ func (a *arrayHostProvider) Provider() (string, error) {
 		return "", errors.New("no host found")
 	}
 
-	for _, host := range a.hosts {
-		if a.freezer.Available(host) {
-			return host, nil
-		}
+	if host, err := a.pick(""); err == nil {
+		return host, nil
 	}
 
-	if a.lastFreezeErr != nil {
-		return "", a.lastFreezeErr
+	a.mutex.Lock()
+	lastFreezeErr := a.lastFreezeErr
+	a.mutex.Unlock()
+	if lastFreezeErr != nil {
+		return "", lastFreezeErr
 	} else {
 		return "", errors.New("all hosts are frozen")
 	}
 }
 
+// pick 按策略从 exclude 以外的可用域名中选择一个
+func (a *arrayHostProvider) pick(exclude string) (string, error) {
+	exclude = hostKey(exclude)
+	hosts := make([]string, 0, len(a.hosts))
+	for _, host := range a.hosts {
+		if key := hostKey(host); key != exclude && a.available(key) && (a.opts.Prober == nil || a.opts.Prober.Available(host)) {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return "", errNoOtherHost
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	switch a.opts.Strategy {
+	case StrategyRoundRobin:
+		a.next++
+		return hosts[a.next%len(hosts)], nil
+	case StrategyEWMA:
+		return a.pickByLatency(hosts), nil
+	}
+	return hosts[0], nil
+}
+
+func (a *arrayHostProvider) available(key string) bool {
+	return a.freezer.Available(key) && (a.opts.Available == nil || a.opts.Available(key))
+}
+
+// pickByLatency 按平均延迟的倒数加权随机选择，未请求过的域名优先
+func (a *arrayHostProvider) pickByLatency(hosts []string) string {
+	weights := make([]float64, len(hosts))
+	total := 0.0
+	for i, host := range hosts {
+		latency, ok := a.ewma[hostKey(host)]
+		if !ok {
+			return host
+		}
+		if latency < 1e-4 {
+			latency = 1e-4
+		}
+		weights[i] = 1 / latency
+		total += weights[i]
+	}
+	r := rand.Float64() * total
+	for i, w := range weights {
+		if r -= w; r < 0 {
+			return hosts[i]
+		}
+	}
+	return hosts[len(hosts)-1]
+}
+
 func (a *arrayHostProvider) Freeze(host string, cause error, duration time.Duration) error {
 	if duration <= 0 {
 		return nil
 	}
 
+	host = hostKey(host)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
 	a.lastFreezeErr = cause
+	a.causes[host] = cause
 	return a.freezer.Freeze(host, duration)
 }
+
+// Unfreeze 实现 Unfreezer
+func (a *arrayHostProvider) Unfreeze(host string) error {
+	host = hostKey(host)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if _, ok := a.causes[host]; !ok {
+		return nil
+	}
+	delete(a.causes, host)
+	return a.freezer.Unfreeze(host)
+}
+
+// Frozen 实现 Inspector
+func (a *arrayHostProvider) Frozen() []FrozenHost {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	items := a.freezer.Frozen()
+	ret := make([]FrozenHost, len(items))
+	for i, item := range items {
+		ret[i] = FrozenHost{Host: item.ItemId, Until: item.Until, Cause: a.causes[item.ItemId]}
+	}
+	return ret
+}
+
+// Record 实现 LatencyRecorder，失败的请求按 1s 计入延迟
+func (a *arrayHostProvider) Record(host string, latency time.Duration, err error) {
+	if a.ewma == nil {
+		return
+	}
+	v := latency.Seconds()
+	if err != nil && v < 1 {
+		v = 1
+	}
+	host = hostKey(host)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if old, ok := a.ewma[host]; ok {
+		v = old + a.opts.EWMADecay*(v-old)
+	}
+	a.ewma[host] = v
+}
+
+// hostKey 去除域名的 scheme，作为域名冻结、过滤及延迟统计的 key
+func hostKey(host string) string {
+	if i := strings.Index(host, "://"); i >= 0 {
+		return host[i+3:]
+	}
+	return host
+}
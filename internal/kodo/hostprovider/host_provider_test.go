@@ -0,0 +1,198 @@
+package hostprovider
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type record struct {
+	host    string
+	latency time.Duration
+	err     error
+}
+
+func TestStrategy(t *testing.T) {
+	errFail := errors.New("fail")
+	notA := func(host string) bool { return host != "a" }
+	cases := []struct {
+		name    string
+		hosts   []string
+		opts    Options
+		records []record
+		check   func(picks map[string]int, seq []string) bool
+	}{
+		{"first", []string{"a", "b"}, Options{}, nil, func(picks map[string]int, seq []string) bool {
+			return picks["a"] == len(seq)
+		}},
+		{"first-available", []string{"a", "b"}, Options{Available: notA}, nil, func(picks map[string]int, seq []string) bool {
+			return picks["b"] == len(seq)
+		}},
+		{"round-robin", []string{"a", "b", "c"}, Options{Strategy: StrategyRoundRobin}, nil, func(picks map[string]int, seq []string) bool {
+			for i := 1; i < len(seq); i++ {
+				if seq[i] == seq[i-1] {
+					return false
+				}
+			}
+			return picks["a"] == picks["b"] && picks["b"] == picks["c"]
+		}},
+		{"round-robin-available", []string{"a", "b", "c"}, Options{Strategy: StrategyRoundRobin, Available: notA}, nil, func(picks map[string]int, seq []string) bool {
+			return picks["a"] == 0 && picks["b"] == picks["c"]
+		}},
+		{"ewma-unrecorded-first", []string{"a", "b"}, Options{Strategy: StrategyEWMA}, []record{
+			{"a", time.Millisecond, nil},
+		}, func(picks map[string]int, seq []string) bool {
+			return picks["b"] == len(seq)
+		}},
+		{"ewma-lower-latency", []string{"a", "b"}, Options{Strategy: StrategyEWMA}, []record{
+			{"a", time.Second, nil}, {"b", 10 * time.Millisecond, nil},
+		}, func(picks map[string]int, seq []string) bool {
+			return picks["b"] > len(seq)*9/10
+		}},
+		{"ewma-errors-are-slow", []string{"a", "b"}, Options{Strategy: StrategyEWMA}, []record{
+			{"a", 10 * time.Millisecond, errFail}, {"b", 100 * time.Millisecond, nil},
+		}, func(picks map[string]int, seq []string) bool {
+			return picks["b"] > len(seq)*3/4
+		}},
+		{"ewma-decay", []string{"a", "b"}, Options{Strategy: StrategyEWMA, EWMADecay: 1}, []record{
+			{"a", 10 * time.Millisecond, nil}, {"b", 100 * time.Millisecond, nil},
+			{"a", time.Second, nil}, // the latest latency wins
+		}, func(picks map[string]int, seq []string) bool {
+			return picks["b"] > len(seq)*3/4
+		}},
+	}
+	for _, c := range cases {
+		hp := New(c.hosts, c.opts)
+		for _, r := range c.records {
+			hp.(LatencyRecorder).Record(r.host, r.latency, r.err)
+		}
+		picks := make(map[string]int)
+		seq := make([]string, 300)
+		for i := range seq {
+			host, err := hp.Provider()
+			if err != nil {
+				t.Fatal(c.name, "Provider:", err)
+			}
+			picks[host]++
+			seq[i] = host
+		}
+		if !c.check(picks, seq) {
+			t.Error(c.name, "picks:", picks)
+		}
+	}
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (p *fakeClock) Now() time.Time {
+	return p.now
+}
+
+func TestFreeze(t *testing.T) {
+	errFail := errors.New("fail")
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	hp := New([]string{"a", "b"}, Options{Clock: clock})
+	if err := hp.Freeze("a", errFail, 0); err != nil { // not frozen
+		t.Fatal("Freeze:", err)
+	}
+	if host, _ := hp.Provider(); host != "a" {
+		t.Fatal("Provider:", host)
+	}
+	hp.Freeze("a", errFail, time.Minute)
+	if host, _ := hp.Provider(); host != "b" {
+		t.Fatal("Provider: frozen host", host)
+	}
+	if host, err := Other(hp, "b"); err == nil {
+		t.Fatal("Other: frozen host", host)
+	}
+	clock.now = clock.now.Add(time.Second)
+	hp.Freeze("b", errFail, time.Minute)
+	if _, err := hp.Provider(); err != errFail {
+		t.Fatal("Provider: all hosts are frozen", err)
+	}
+	frozen := hp.(Inspector).Frozen()
+	if len(frozen) != 2 || frozen[0].Host != "a" || frozen[0].Cause != errFail {
+		t.Fatal("Frozen:", frozen)
+	}
+	hp.(Unfreezer).Unfreeze("a")
+	if host, _ := hp.Provider(); host != "a" {
+		t.Fatal("Provider: unfrozen host", host)
+	}
+	if host, _ := Other(hp, "b"); host != "a" {
+		t.Fatal("Other:", host)
+	}
+	clock.now = clock.now.Add(30 * time.Second)
+	hp.Freeze("a", errFail, time.Minute)
+	clock.now = clock.now.Add(30 * time.Second)
+	if host, _ := hp.Provider(); host != "b" { // b is thawed, a is still frozen
+		t.Fatal("Provider: thawed host", host)
+	}
+}
+
+func TestCache(t *testing.T) {
+	c := NewCache(Options{})
+	hp := c.Get([]string{"a", "b"})
+	if c.Get([]string{"a", "b"}) != hp || c.Get([]string{"b", "a"}) == hp {
+		t.Fatal("Get: not cached by hosts")
+	}
+	hp.Freeze("a", errors.New("fail"), time.Minute)
+	if frozen := c.Frozen(); len(frozen) != 1 || frozen[0].Host != "a" {
+		t.Fatal("Frozen:", frozen)
+	}
+}
+
+func TestHostKeys(t *testing.T) {
+	var queried []string
+	hp := New([]string{"https://a", "https://b"}, Options{Available: func(host string) bool {
+		queried = append(queried, host)
+		return true
+	}})
+	hp.Freeze("a", errors.New("fail"), time.Minute) // frozen by a host without scheme
+	if host, _ := hp.Provider(); host != "https://b" {
+		t.Fatal("Provider:", host)
+	}
+	if frozen := hp.(Inspector).Frozen(); len(frozen) != 1 || frozen[0].Host != "a" {
+		t.Fatal("Frozen:", frozen)
+	}
+	hp.(Unfreezer).Unfreeze("https://a") // unfrozen by a host with scheme
+	if host, _ := hp.Provider(); host != "https://a" {
+		t.Fatal("Provider:", host)
+	}
+	for _, host := range queried {
+		if host != "a" && host != "b" {
+			t.Fatal("Available: host with scheme", host)
+		}
+	}
+	if other, _ := Other(hp, "a"); other != "https://b" {
+		t.Fatal("Other:", other)
+	}
+}
+
+func TestConcurrentFreeze(t *testing.T) {
+	hp := New([]string{"a", "b", "c"}, Options{Strategy: StrategyRoundRobin})
+	errFail := errors.New("fail")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				host, err := hp.Provider()
+				if err != nil {
+					continue
+				}
+				if j%3 == 0 {
+					hp.Freeze(host, errFail, time.Millisecond)
+				} else {
+					hp.(LatencyRecorder).Record(host, time.Millisecond, nil)
+					hp.(Unfreezer).Unfreeze(host)
+				}
+				hp.(Inspector).Frozen()
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,123 @@
+package hostprovider
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ProberConfig struct {
+	Interval time.Duration // 健康检查的间隔（默认：10s）
+	Timeout  time.Duration // 单次健康检查的超时时间（默认：3s）
+
+	// 可选，检查域名是否健康，默认检查能否建立 tcp 连接
+	Probe func(ctx context.Context, host string) error
+}
+
+// Prober 主动检查域名的健康状态，健康检查失败的域名不可用，直到再次检查成功。
+// 域名在第一次被 Available 查询时加入检查，可用作 Options.Prober。域名应带 scheme，
+// 默认的检查按 scheme 连接 80 或 443 端口
+type Prober struct {
+	config  ProberConfig
+	hosts   map[string]bool // 域名 => 是否健康
+	mutex   sync.Mutex
+	closing chan struct{}
+	once    sync.Once
+}
+
+func NewProber(config ProberConfig) *Prober {
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 3 * time.Second
+	}
+	if config.Probe == nil {
+		config.Probe = dialProbe
+	}
+	p := &Prober{
+		config:  config,
+		hosts:   make(map[string]bool),
+		closing: make(chan struct{}),
+	}
+	go p.loop()
+	return p
+}
+
+// Available 返回域名是否健康，未检查过的域名视为健康
+func (p *Prober) Available(host string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	healthy, ok := p.hosts[host]
+	if !ok {
+		p.hosts[host] = true
+		return true
+	}
+	return healthy
+}
+
+// Close 停止健康检查
+func (p *Prober) Close() error {
+	p.once.Do(func() { close(p.closing) })
+	return nil
+}
+
+func (p *Prober) loop() {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.probeAll()
+		case <-p.closing:
+			return
+		}
+	}
+}
+
+func (p *Prober) probeAll() {
+	p.mutex.Lock()
+	hosts := make([]string, 0, len(p.hosts))
+	for host := range p.hosts {
+		hosts = append(hosts, host)
+	}
+	p.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), p.config.Timeout)
+			err := p.config.Probe(ctx, host)
+			cancel()
+			p.mutex.Lock()
+			p.hosts[host] = err == nil
+			p.mutex.Unlock()
+		}(host)
+	}
+	wg.Wait()
+}
+
+// dialProbe 检查能否与域名建立 tcp 连接，域名未指定端口时按 scheme 连接 80 或 443 端口（无 scheme 时为 443）
+func dialProbe(ctx context.Context, host string) error {
+	port := "443"
+	if strings.HasPrefix(host, "http://") {
+		port = "80"
+	}
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "http://"), "https://")
+	if i := strings.IndexByte(host, '/'); i >= 0 {
+		host = host[:i]
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, port)
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,61 @@
+package hostprovider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProber(t *testing.T) {
+	var bad int32 = 1
+	p := NewProber(ProberConfig{Interval: 5 * time.Millisecond, Probe: func(ctx context.Context, host string) error {
+		if host == "http://b" && atomic.LoadInt32(&bad) == 1 {
+			return errors.New("unhealthy")
+		}
+		return nil
+	}})
+	defer p.Close()
+
+	hp := New([]string{"http://b", "http://a"}, Options{Prober: p})
+	if host, _ := hp.Provider(); host != "http://b" { // not probed yet
+		t.Fatal("Provider:", host)
+	}
+	p.Available("http://a")
+	waitFor(t, func() bool { return !p.Available("http://b") })
+	if host, _ := hp.Provider(); host != "http://a" {
+		t.Fatal("Provider: unhealthy host", host)
+	}
+	atomic.StoreInt32(&bad, 0)
+	waitFor(t, func() bool { return p.Available("http://b") })
+	if !p.Available("http://a") {
+		t.Fatal("Available: healthy host")
+	}
+}
+
+func TestDialProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ctx := context.Background()
+	if err = dialProbe(ctx, "http://"+addr+"/path"); err != nil {
+		t.Fatal("dialProbe:", err)
+	}
+	ln.Close()
+	if err = dialProbe(ctx, addr); err == nil {
+		t.Fatal("dialProbe: closed port")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// FieldLogger 带级别及键值对字段的日志接口，调用方可实现该接口以对接其日志系统，其方法会被并发调用
+type FieldLogger interface {
+	// Enabled 返回是否输出 level 级别的日志，用于避免构造不输出的日志
+	Enabled(level LogLevel) bool
+
+	// Log 输出日志，keyvals 为交替的键（string）与值
+	Log(level LogLevel, msg string, keyvals ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Enabled(level LogLevel) bool                            { return false }
+func (nopLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {}
+
+// Nop 不输出任何日志
+var Nop FieldLogger = nopLogger{}
+
+type loggerKey struct{}
+
+// WithLogger 返回带有 logger 的 context，其请求的调试信息（如 dump）输出到 logger
+func WithLogger(ctx context.Context, logger FieldLogger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext 返回 context 带有的 logger，没有时返回 Nop
+func FromContext(ctx context.Context) FieldLogger {
+	if l, ok := ctx.Value(loggerKey{}).(FieldLogger); ok {
+		return l
+	}
+	return Nop
+}
+
+type textLogger struct {
+	l     *log.Logger
+	level LogLevel
+}
+
+// NewFieldLogger 返回以 "[D] msg key=value ..." 格式输出到 l 的 FieldLogger，只输出不低于 level 的日志
+func NewFieldLogger(l *log.Logger, level LogLevel) FieldLogger {
+	return &textLogger{l: l, level: level}
+}
+
+func (p *textLogger) Enabled(level LogLevel) bool {
+	return level >= p.level
+}
+
+func (p *textLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	if !p.Enabled(level) {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(levelPrefix(level))
+	b.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		b.WriteByte(' ')
+		b.WriteString(fmt.Sprint(keyvals[i]))
+		b.WriteByte('=')
+		if i+1 < len(keyvals) {
+			b.WriteString(formatValue(keyvals[i+1]))
+		} else {
+			b.WriteString("<missing>")
+		}
+	}
+	p.l.Println(b.String())
+}
+
+func levelPrefix(level LogLevel) string {
+	switch level {
+	case LogDebug:
+		return DebugPrefix
+	case LogInfo:
+		return InfoPrefix
+	case LogWarn:
+		return WarnPrefix
+	}
+	return ErrorPrefix
+}
+
+// formatValue 格式化字段的值，含空白或引号的值加上引号
+func formatValue(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
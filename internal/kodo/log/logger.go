@@ -37,12 +37,16 @@ const (
 
 	// Warn
 	LogWarn
+
+	// Error
+	LogError
 )
 
 const (
 	InfoPrefix  = "[I] "
 	DebugPrefix = "[D] "
 	WarnPrefix  = "[W] "
+	ErrorPrefix = "[E] "
 )
 
 func (l *Logger) Info(v ...interface{}) {
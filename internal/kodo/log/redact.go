@@ -0,0 +1,25 @@
+package log
+
+import (
+	"regexp"
+)
+
+const redacted = "REDACTED"
+
+var (
+	// Authorization 头保留认证类型（如 Qiniu、UpToken），隐去 AK 及签名
+	reAuthorization = regexp.MustCompile(`(?mi)^(authorization:[ \t]*)(\w+[ \t]+)?[^\r\n]*`)
+
+	// 私有下载链接的 token、uc 查询的 ak 等 url 参数
+	reQuery = regexp.MustCompile(`([?&](?:token|ak|uploadToken|upToken)=)[^&\s#"']*`)
+
+	// 表单上传的 token 字段
+	reFormToken = regexp.MustCompile(`(name="token"\r?\n(?:[^\r\n]+\r?\n)*\r?\n)[^\r\n]*`)
+)
+
+// Redact 隐去请求或响应 dump（及含请求 url 的错误信息）中的 Authorization 头、上传凭证及 AK，用于输出调试日志
+func Redact(dump string) string {
+	dump = reAuthorization.ReplaceAllString(dump, "${1}${2}"+redacted)
+	dump = reQuery.ReplaceAllString(dump, "${1}"+redacted)
+	return reFormToken.ReplaceAllString(dump, "${1}"+redacted)
+}
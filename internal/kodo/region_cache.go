@@ -0,0 +1,222 @@
+package kodo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xushiwei/kodofs/internal/kodo/client"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// RegionCache 缓存 uc 查询到的空间区域，其方法会被并发调用
+type RegionCache interface {
+	// Load 返回未过期的区域
+	Load(key string) (region *Region, ok bool)
+
+	// Store 缓存区域，ttl 后过期
+	Store(key string, region *Region, ttl time.Duration)
+
+	// Delete 使区域失效，下次使用时重新查询
+	Delete(key string)
+}
+
+type nopRegionCache struct{}
+
+func (nopRegionCache) Load(key string) (*Region, bool)                     { return nil, false }
+func (nopRegionCache) Store(key string, region *Region, ttl time.Duration) {}
+func (nopRegionCache) Delete(key string)                                   {}
+
+// NopRegionCache 不缓存区域，每次都查询 uc 服务
+var NopRegionCache RegionCache = nopRegionCache{}
+
+type regionV2CacheValue struct {
+	Region   *Region   `json:"region"`
+	Deadline time.Time `json:"deadline"`
+}
+
+type regionV2CacheMap map[string]regionV2CacheValue
+
+type memoryRegionCache struct {
+	items sync.Map // key => regionV2CacheValue
+}
+
+// NewMemoryRegionCache 返回缓存在内存中的 RegionCache
+func NewMemoryRegionCache() RegionCache {
+	return &memoryRegionCache{}
+}
+
+func (p *memoryRegionCache) Load(key string) (*Region, bool) {
+	if v, ok := p.items.Load(key); ok && time.Now().Before(v.(regionV2CacheValue).Deadline) {
+		return v.(regionV2CacheValue).Region, true
+	}
+	return nil, false
+}
+
+func (p *memoryRegionCache) Store(key string, region *Region, ttl time.Duration) {
+	p.items.Store(key, regionV2CacheValue{Region: region, Deadline: time.Now().Add(ttl)})
+}
+
+func (p *memoryRegionCache) Delete(key string) {
+	p.items.Delete(key)
+}
+
+// -----------------------------------------------------------------------------------------
+
+const regionV2CacheFileName = "query_v2_00.cache.json"
+
+type FileRegionCacheOptions struct {
+	Path     string      // 缓存文件路径（默认：os.UserCacheDir()/qiniu-golang-sdk/query_v2_00.cache.json）
+	DirPerm  os.FileMode // 创建缓存文件所在目录的权限（默认：0700）
+	FilePerm os.FileMode // 创建缓存文件的权限（默认：0600）
+}
+
+// fileRegionCache 缓存在内存中，并持久化到文件，以在进程间共享；文件不可读写时（如只读容器）或没有用户缓存目录时只缓存在内存中
+type fileRegionCache struct {
+	memoryRegionCache
+	opts   FileRegionCacheOptions
+	loaded sync.Once
+	mutex  sync.Mutex // 串行写缓存文件
+}
+
+// NewFileRegionCache 返回持久化到文件的 RegionCache，缓存文件在第一次使用时读取
+func NewFileRegionCache(opts FileRegionCacheOptions) RegionCache {
+	if opts.Path == "" {
+		opts.Path = DefaultRegionCachePath()
+	}
+	if opts.DirPerm == 0 {
+		opts.DirPerm = 0700
+	}
+	if opts.FilePerm == 0 {
+		opts.FilePerm = 0600
+	}
+	return &fileRegionCache{opts: opts}
+}
+
+func (p *fileRegionCache) Load(key string) (*Region, bool) {
+	p.loaded.Do(p.load)
+	return p.memoryRegionCache.Load(key)
+}
+
+func (p *fileRegionCache) Store(key string, region *Region, ttl time.Duration) {
+	p.loaded.Do(p.load)
+	p.memoryRegionCache.Store(key, region, ttl)
+	p.store()
+}
+
+func (p *fileRegionCache) Delete(key string) {
+	p.loaded.Do(p.load)
+	if _, ok := p.items.LoadAndDelete(key); ok {
+		p.store()
+	}
+}
+
+func (p *fileRegionCache) load() {
+	if p.opts.Path == "" {
+		return
+	}
+	cacheFile, err := os.Open(p.opts.Path)
+	if err != nil {
+		return
+	}
+	defer cacheFile.Close()
+
+	var cacheMap regionV2CacheMap
+	if err = json.NewDecoder(cacheFile).Decode(&cacheMap); err != nil {
+		return
+	}
+	for cacheKey, cacheValue := range cacheMap {
+		p.items.Store(cacheKey, cacheValue)
+	}
+}
+
+func (p *fileRegionCache) store() {
+	if p.opts.Path == "" {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	err := os.MkdirAll(filepath.Dir(p.opts.Path), p.opts.DirPerm)
+	if err != nil {
+		return
+	}
+
+	cacheFile, err := os.OpenFile(p.opts.Path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, p.opts.FilePerm)
+	if err != nil {
+		return
+	}
+	defer cacheFile.Close()
+
+	cacheMap := make(regionV2CacheMap)
+	p.items.Range(func(cacheKey, cacheValue interface{}) bool {
+		cacheMap[cacheKey.(string)] = cacheValue.(regionV2CacheValue)
+		return true
+	})
+	json.NewEncoder(cacheFile).Encode(cacheMap)
+}
+
+// DefaultRegionCachePath 返回默认的缓存文件路径，位于当前用户的缓存目录下，以免不同用户共享同一个文件；
+// 没有用户缓存目录时返回空串
+func DefaultRegionCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "qiniu-golang-sdk", regionV2CacheFileName)
+}
+
+// DefaultRegionCache 未指定 RegionCache 时使用的缓存，只缓存在内存中；需要在进程间共享时使用 NewFileRegionCache
+var DefaultRegionCache = NewMemoryRegionCache()
+
+// -----------------------------------------------------------------------------------------
+
+func (options *UCApiOptions) regionCache() RegionCache {
+	if options.RegionCache != nil {
+		return options.RegionCache
+	}
+	return DefaultRegionCache
+}
+
+func regionCacheKey(ak, bucket string, options *UCApiOptions) string {
+	key := fmt.Sprintf("%s:%s", ak, bucket)
+	if len(options.Hosts) > 0 {
+		key += ":" + strings.Join(options.Hosts, ",")
+	}
+	return key
+}
+
+// InvalidateRegion 使缓存的空间区域失效，下次使用时重新查询
+func InvalidateRegion(ak, bucket string, options UCApiOptions) {
+	options.regionCache().Delete(regionCacheKey(ak, bucket, &options))
+}
+
+// shouldInvalidateRegion 判断请求失败后是否需要使缓存的区域失效：空间不存在（可能已被删除重建到其他区域），
+// 或域名无法连接（可能已下线）
+func shouldInvalidateRegion(err error) bool {
+	if errors.Is(err, client.ErrNoSuchBucket) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// invalidateRegion 在 err 需要时使 bucket 缓存的区域失效，指定了 Region 时不查询，无需失效
+func (c *Config) invalidateRegion(ak, bucket string, err error) {
+	if err != nil && c.GetRegion() == nil && shouldInvalidateRegion(err) {
+		InvalidateRegion(ak, bucket, UCApiOptions{Hosts: c.UcHosts, RegionCache: c.RegionCache})
+	}
+}
+
+// -----------------------------------------------------------------------------------------
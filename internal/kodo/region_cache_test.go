@@ -0,0 +1,76 @@
+package kodo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xushiwei/kodofs/internal/kodo/client"
+)
+
+func TestRegionCache(t *testing.T) {
+	if _, ok := DefaultRegionCache.(*memoryRegionCache); !ok {
+		t.Fatalf("DefaultRegionCache: %T", DefaultRegionCache)
+	}
+
+	path := filepath.Join(t.TempDir(), "cache", regionV2CacheFileName)
+	region := &Region{RsHost: "rs.example.com"}
+	c := NewFileRegionCache(FileRegionCacheOptions{Path: path})
+	c.Store("ak:bkt", region, time.Minute)
+	c.Store("ak:expired", region, -time.Second)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal("Stat:", err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Fatal("FilePerm:", perm)
+	}
+
+	c = NewFileRegionCache(FileRegionCacheOptions{Path: path}) // another process
+	if r, ok := c.Load("ak:bkt"); !ok || r.RsHost != region.RsHost {
+		t.Fatal("Load:", r, ok)
+	}
+	if _, ok := c.Load("ak:expired"); ok {
+		t.Fatal("Load: expired region")
+	}
+	c.Delete("ak:bkt")
+	if _, ok := NewFileRegionCache(FileRegionCacheOptions{Path: path}).Load("ak:bkt"); ok {
+		t.Fatal("Load: deleted region")
+	}
+}
+
+func TestInvalidateRegion(t *testing.T) {
+	region := &Region{RsHost: "rs.example.com"}
+	cache := NewMemoryRegionCache()
+	cfg := &Config{UcHosts: []string{"uc.example.com"}, RegionCache: cache}
+	options := UCApiOptions{Hosts: cfg.UcHosts, RegionCache: cache}
+	key := regionCacheKey("ak", "bkt", &options)
+	if key != "ak:bkt:uc.example.com" {
+		t.Fatal("regionCacheKey:", key)
+	}
+
+	cases := []struct {
+		err        error
+		invalidate bool
+	}{
+		{nil, false},
+		{&client.ErrorInfo{Code: 612}, false},
+		{&client.ErrorInfo{Code: 503}, false},
+		{fmt.Errorf("stat: %w", &client.ErrorInfo{Code: 631}), true},
+		{&net.DNSError{Err: "no such host", Name: "rs.example.com"}, true},
+		{&url.Error{Op: "Post", URL: "http://rs", Err: &net.OpError{Op: "dial", Err: errors.New("refused")}}, true},
+		{&net.OpError{Op: "read", Err: errors.New("reset")}, false},
+	}
+	for _, c := range cases {
+		cache.Store(key, region, time.Minute)
+		cfg.invalidateRegion("ak", "bkt", c.err)
+		if _, ok := cache.Load(key); ok == c.invalidate {
+			t.Errorf("invalidateRegion(%v): cached %v", c.err, ok)
+		}
+	}
+}
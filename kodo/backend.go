@@ -0,0 +1,136 @@
+package kodo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/xushiwei/kodofs/backend"
+	"github.com/xushiwei/kodofs/internal/kodo"
+	clientv1 "github.com/xushiwei/kodofs/internal/kodo/client"
+)
+
+const (
+	downloadExpires = time.Hour
+)
+
+var (
+	_ backend.Backend = (*Bucket)(nil)
+)
+
+// -----------------------------------------------------------------------------------------
+
+// List implements backend.Backend.List. Keys of the result are relative to the root of b.
+func (b *Bucket) List(ctx context.Context, prefix, delimiter, marker string, limit int) (_ *backend.ListResult, err error) {
+	ctx, span := b.startSpan(ctx, "List", prefix)
+	defer func() { span.End(err) }()
+	if limit <= 0 || limit > backend.DefaultListLimit {
+		limit = backend.DefaultListLimit
+	}
+	root := b.root
+	ret, hasNext, err := b.m.ListFilesWithContext(ctx, b.bucket,
+		kodo.ListInputOptionsPrefix(root+prefix), kodo.ListInputOptionsDelimiter(delimiter),
+		kodo.ListInputOptionsMarker(marker), kodo.ListInputOptionsLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	items := make([]backend.ObjectInfo, len(ret.Items))
+	for i, item := range ret.Items {
+		items[i] = backend.ObjectInfo{
+			Key:      item.Key[len(root):],
+			Size:     item.Fsize,
+			Hash:     item.Hash,
+			MimeType: item.MimeType,
+			PutTime:  fromPutTime(item.PutTime),
+		}
+	}
+	prefixes := make([]string, len(ret.CommonPrefixes))
+	for i, cp := range ret.CommonPrefixes {
+		prefixes[i] = cp[len(root):]
+	}
+	next := ""
+	if hasNext {
+		next = ret.Marker
+	}
+	return &backend.ListResult{Items: items, CommonPrefixes: prefixes, Marker: next}, nil
+}
+
+// Stat implements backend.Backend.Stat.
+func (b *Bucket) Stat(ctx context.Context, name string) (_ *backend.ObjectInfo, err error) {
+	ctx, span := b.startSpan(ctx, "Stat", name)
+	defer func() { span.End(err) }()
+	key := b.key(name)
+	info, err := b.m.StatWithContext(ctx, b.bucket, key)
+	if err != nil {
+		return nil, pathError("stat", name, err)
+	}
+	return &backend.ObjectInfo{
+		Key:      key[len(b.root):],
+		Size:     info.Fsize,
+		Hash:     info.Hash,
+		MimeType: info.MimeType,
+		PutTime:  fromPutTime(info.PutTime),
+	}, nil
+}
+
+// Open implements backend.Backend.Open. It downloads the file `name` from the download host
+// (see Options.IoHost) by a signed private url.
+func (b *Bucket) Open(ctx context.Context, name string, offset, length int64) (_ io.ReadCloser, err error) {
+	if length == 0 {
+		return http.NoBody, nil
+	}
+	ctx, span := b.startSpan(ctx, "Open", name)
+	defer func() { span.End(err) }()
+	header := http.Header{}
+	if offset > 0 || length > 0 {
+		rg := "bytes=" + strconv.FormatInt(offset, 10) + "-"
+		if length > 0 {
+			rg += strconv.FormatInt(offset+length-1, 10)
+		}
+		header.Set("Range", rg)
+	}
+	resp, err := b.m.DownloadWithContext(ctx, b.bucket, b.key(name), header, time.Now().Add(downloadExpires))
+	if err != nil {
+		var e *clientv1.ErrorInfo
+		if errors.As(err, &e) && e.Code == http.StatusRequestedRangeNotSatisfiable { // offset is out of the file
+			return http.NoBody, nil
+		}
+		return nil, pathError("open", name, err)
+	}
+	if resp.StatusCode == http.StatusOK { // the server ignores Range
+		if offset > 0 {
+			if _, err = io.CopyN(io.Discard, resp.Body, offset); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+		}
+		if length > 0 {
+			return struct {
+				io.Reader
+				io.Closer
+			}{io.LimitReader(resp.Body, length), resp.Body}, nil
+		}
+	}
+	return resp.Body, nil
+}
+
+// Put implements backend.Backend.Put.
+func (b *Bucket) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	return b.Upload(ctx, name, r, size)
+}
+
+// pathError wraps errors of kodo services into *fs.PathError. They still match fs.ErrNotExist
+// etc. by errors.Is (see package kodoerr), and keep details such as the reqid.
+func pathError(op, name string, err error) error {
+	var e *clientv1.ErrorInfo
+	if errors.As(err, &e) {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return err
+}
+
+// -----------------------------------------------------------------------------------------
@@ -0,0 +1,37 @@
+package kodo
+
+import (
+	"github.com/xushiwei/kodofs/internal/kodo/clientv2"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// CircuitBreaker is a per-host circuit breaker of requests to kodo services. If requests to
+// a host fail too often, the circuit of the host opens, and requests are sent to backup
+// hosts (or fail fast with ErrCircuitOpen) until a cool-down period passes. Then a few
+// probe requests are let through (half-open), and the circuit closes if they succeed.
+// A CircuitBreaker can be shared by buckets (see Options.CircuitBreaker).
+type CircuitBreaker = clientv2.CircuitBreaker
+
+// CircuitBreakerConfig represents the options of a CircuitBreaker, such as the failure
+// ratio to open a circuit, the cool-down period, and the OnStateChange event for alerting.
+type CircuitBreakerConfig = clientv2.CircuitBreakerConfig
+
+// CircuitState is the state of a circuit.
+type CircuitState = clientv2.CircuitState
+
+const (
+	CircuitClosed   = clientv2.CircuitClosed
+	CircuitOpen     = clientv2.CircuitOpen
+	CircuitHalfOpen = clientv2.CircuitHalfOpen
+)
+
+// ErrCircuitOpen is returned if the circuits of all hosts of a service are open.
+var ErrCircuitOpen = clientv2.ErrCircuitOpen
+
+// NewCircuitBreaker creates a CircuitBreaker. Zero values of `config` mean defaults.
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	return clientv2.NewCircuitBreaker(config)
+}
+
+// -----------------------------------------------------------------------------------------
@@ -2,14 +2,19 @@ package kodo
 
 import (
 	"context"
+	"io"
 	"io/fs"
-	"log"
+	"net/http"
+	"path"
 	"strings"
 	"time"
 
 	xfs "github.com/qiniu/x/http/fs"
+	"github.com/xushiwei/kodofs/backend"
 	"github.com/xushiwei/kodofs/internal/kodo"
 	"github.com/xushiwei/kodofs/internal/kodo/auth"
+	"github.com/xushiwei/kodofs/internal/kodo/clientv2"
+	"github.com/xushiwei/kodofs/internal/kodo/hostprovider"
 )
 
 var (
@@ -31,86 +36,223 @@ type Bucket struct {
 	mac    *auth.Credentials
 	m      *kodo.BucketManager
 	bucket string
+	root   string // key prefix of this bucket view: "" or "<prefix>/"
+	logger Logger
+}
+
+// Options represents the options of opening a Bucket object.
+type Options struct {
+	// Region is the id of the region that the bucket is located in, eg. "z0". If it is in
+	// Regions, hosts of the region are used without querying the uc service. If it is empty
+	// or unknown, the region is discovered by querying the uc service.
+	Region string
+
+	// StaticRegion specifies hosts of kodo services of the bucket, eg. hosts of a private-cloud
+	// deployment. If it is set, Region and UcHosts are ignored and the uc service isn't queried.
+	StaticRegion *Region
+
+	// UseHTTPS specifies whether to access kodo services by https or not. It doesn't apply
+	// to hosts which have schemes.
+	UseHTTPS bool
+
+	// UcHosts specifies hosts of the uc service which is used to discover the region of
+	// the bucket. If a host doesn't have a scheme, https is used. If it is empty, hosts of
+	// the public cloud are used.
+	UcHosts []string
+
+	// RegionCache caches the region discovered by querying the uc service. If it is nil,
+	// DefaultRegionCache is used.
+	RegionCache RegionCache
+
+	// RegionTTL overrides the time to live of the cached region. If it is 0, the ttl returned
+	// by the uc service is used.
+	RegionTTL time.Duration
+
+	// IoHost specifies the host to download files (see Bucket.Open), eg. "iovip.qiniuio.com".
+	// If it doesn't have a scheme, UseHTTPS decides the scheme. If it is empty, the io host of
+	// the region is used.
+	IoHost string
+
+	// Client specifies the http client to access kodo services (rs, rsf, uc, up and io).
+	// If it is nil, a client is created from Transport.
+	Client *http.Client
+
+	// Transport specifies options of the http transport if Client is nil. If both are nil,
+	// http.DefaultTransport is used, which has no response timeouts.
+	Transport *TransportOptions
+
+	// CircuitBreaker specifies the per-host circuit breaker of requests to rs, rsf and io
+	// services. It can be shared by buckets. If it is nil, circuits are never opened.
+	CircuitBreaker *CircuitBreaker
+
+	// RateLimiter limits request and byte rates of the bucket. It can be shared by buckets.
+	// If it is nil, rates are unlimited.
+	RateLimiter *RateLimiter
+
+	// HostStrategy specifies how to choose a host among the hosts of a service.
+	HostStrategy HostStrategy
+
+	// HostProber specifies the health checker of hosts. If it is nil, hosts are only skipped
+	// after failures.
+	HostProber *HostProber
+
+	// Metrics collects metrics of requests to kodo services (see package metrics for an
+	// expvar adapter). If it is nil, metrics aren't collected.
+	Metrics Metrics
+
+	// Tracer creates spans of operations and their HTTP requests. If it is nil, operations
+	// aren't traced.
+	Tracer Tracer
+
+	// Logger logs requests to kodo services and operations of the bucket. If it is nil,
+	// DefaultLogger is used.
+	Logger Logger
+
+	// HedgeDelay specifies the delay of hedged requests: if a download or listing request
+	// doesn't respond within the delay, a second request is sent to another host, and the
+	// first response wins. If it is 0, requests aren't hedged.
+	HedgeDelay time.Duration
 }
 
 // NewBucket opens a Bucket object.
 func (mac *Credentials) NewBucket(bucket string) *Bucket {
+	return mac.NewBucketEx(bucket, nil)
+}
+
+// NewBucketEx opens a Bucket object with options.
+func (mac *Credentials) NewBucketEx(bucket string, opts *Options) *Bucket {
+	if opts == nil {
+		opts = &Options{}
+	}
+	cfg := &kodo.Config{UseHTTPS: opts.UseHTTPS, UcHosts: opts.UcHosts, IoHost: opts.IoHost, Metrics: opts.Metrics,
+		Tracer: opts.Tracer, Logger: opts.logger(), RegionCache: opts.RegionCache, RegionTTL: opts.RegionTTL}
+	if opts.RateLimiter != nil {
+		cfg.Interceptors = append(cfg.Interceptors, opts.RateLimiter.Interceptor())
+	}
+	if opts.HostStrategy != HostFirst || opts.HostProber != nil || opts.CircuitBreaker != nil {
+		hpOpts := hostprovider.Options{Strategy: opts.HostStrategy, Prober: opts.HostProber}
+		if opts.CircuitBreaker != nil {
+			hpOpts.Available = opts.CircuitBreaker.Available // skip hosts with open circuits
+		}
+		cfg.HostProviders = hostprovider.NewCache(hpOpts)
+	}
+	if opts.StaticRegion != nil {
+		cfg.Region = opts.StaticRegion.toInternal(opts.UseHTTPS)
+	} else if opts.Region != "" {
+		if r, ok := Regions[opts.Region]; ok {
+			cfg.Region = r.toInternal(opts.UseHTTPS)
+		} else if l := cfg.Logger; l.Enabled(LogWarn) {
+			l.Log(LogWarn, "kodo.NewBucket: unknown region", "region", opts.Region)
+		}
+	}
 	auth := (*auth.Credentials)(mac)
-	m := kodo.NewBucketManager(auth, nil)
-	return &Bucket{auth, m, bucket}
+	m := kodo.NewBucketManagerExWithOptions(auth, cfg, opts.httpClient(), kodo.BucketManagerOptions{
+		CircuitBreaker: opts.CircuitBreaker,
+		HedgeDelay:     opts.HedgeDelay,
+	})
+	return &Bucket{auth, m, bucket, "", opts.Logger}
 }
 
 func (b *Bucket) Credentials() *Credentials {
 	return (*Credentials)(b.mac)
 }
 
+// HTTPClient returns the http client to access kodo services (see Options.Client).
+func (b *Bucket) HTTPClient() *http.Client {
+	return b.m.Client.Client
+}
+
 func (b *Bucket) Name() string {
 	return b.bucket
 }
 
+// Root returns the key prefix that this bucket view is rooted at ("" for the whole bucket).
+func (b *Bucket) Root() string {
+	return strings.TrimSuffix(b.root, "/")
+}
+
+// Sub returns a view of the bucket rooted at `prefix` (relative to the root of b).
+// All operations of the returned Bucket are scoped to the prefix, and paths can't escape
+// it via "..".
+func (b *Bucket) Sub(prefix string) *Bucket {
+	ret := *b
+	if rel := relKey(prefix); rel != "" {
+		ret.root = b.root + rel + "/"
+	}
+	return &ret
+}
+
+// relKey converts a path into a key relative to the root (without leading "/").
+// It cleans the path first so that the result never escapes the root.
+func relKey(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+func (b *Bucket) key(name string) string {
+	return b.root + relKey(name)
+}
+
+func (b *Bucket) dirKey(dir string) string {
+	key := b.key(dir)
+	if key != "" && !strings.HasSuffix(key, "/") {
+		key += "/"
+	}
+	return key
+}
+
+// Upload uploads the content `r` (`fsize` bytes) as the file `name`.
+func (b *Bucket) Upload(ctx context.Context, name string, r io.Reader, fsize int64) (err error) {
+	ctx, span := b.startSpan(ctx, "Upload", name)
+	defer func() { span.End(err) }()
+	if err = upload(ctx, b.mac, b.m.Cfg, b.m.Client, b.bucket, b.key(name), r, fsize); err != nil {
+		err = pathError("upload", name, err)
+	}
+	return
+}
+
+// Delete deletes the file `name`.
+func (b *Bucket) Delete(ctx context.Context, name string) (err error) {
+	ctx, span := b.startSpan(ctx, "Delete", name)
+	defer func() { span.End(err) }()
+	if err = b.m.DeleteWithContext(ctx, b.bucket, b.key(name)); err != nil {
+		err = pathError("delete", name, err)
+	}
+	return
+}
+
 // -----------------------------------------------------------------------------------------
 
-type WalkFunc = func(path string, info fs.FileInfo, err error) error
+type WalkFunc = backend.WalkFunc
 
+// WalkContext walks all files under the directory `dir`. It stops if `fn` returns an error,
+// and returns the error.
 func (b *Bucket) WalkContext(ctx context.Context, dir string, fn WalkFunc) (err error) {
-	m, bucket := b.m, b.bucket
-	if dir == "/" {
-		dir = ""
-	} else {
-		dir = strings.TrimPrefix(dir, "/")
-		if !strings.HasSuffix(dir, "/") {
-			dir += "/"
-		}
-	}
-	prefix := kodo.ListInputOptionsPrefix(dir)
-	limit := kodo.ListInputOptionsLimit(1000)
-	marker := ""
-	for {
-		ret, hasNext, e := m.ListFilesWithContext(ctx, bucket, prefix, limit, kodo.ListInputOptionsMarker(marker))
-		if e != nil {
-			return e
-		}
-		if debugNet {
-			log.Println("kodo.List:", dir, "hasNext:", hasNext, "items:", len(ret.Items))
-		}
+	ctx, span := b.startSpan(ctx, "WalkContext", dir)
+	defer func() { span.End(err) }()
+	root := b.root
+	dir = b.dirKey(dir)
+	return b.listPages(ctx, dir, "", func(ret *kodo.ListFilesRet) error {
 		for _, item := range ret.Items {
 			key := item.Key
 			name := key[len(dir):]
-			fn("/"+key, xfs.NewFileInfo(name, item.Fsize), nil)
-		}
-		if !hasNext {
-			break
+			fi := xfs.NewFileInfo(name, item.Fsize)
+			fi.Mtime = fromPutTime(item.PutTime)
+			if err := fn("/"+key[len(root):], fi, nil); err != nil {
+				return err
+			}
 		}
-		marker = ret.Marker
-	}
-	return
+		return nil
+	})
 }
 
 // -----------------------------------------------------------------------------------------
 
 func (b *Bucket) ReaddirContext(ctx context.Context, dir string) (fis []fs.FileInfo, err error) {
-	m, bucket := b.m, b.bucket
-	if dir == "/" {
-		dir = ""
-	} else {
-		dir = strings.TrimPrefix(dir, "/")
-		if !strings.HasSuffix(dir, "/") {
-			dir += "/"
-		}
-	}
-	delimiter := kodo.ListInputOptionsDelimiter("/")
-	prefix := kodo.ListInputOptionsPrefix(dir)
-	limit := kodo.ListInputOptionsLimit(1000)
-	marker := ""
+	ctx, span := b.startSpan(ctx, "ReaddirContext", dir)
+	defer func() { span.End(err) }()
+	dir = b.dirKey(dir)
 	fis = make([]fs.FileInfo, 0, 64)
-	for {
-		ret, hasNext, e := m.ListFilesWithContext(ctx, bucket, prefix, delimiter, limit, kodo.ListInputOptionsMarker(marker))
-		if e != nil {
-			return nil, e
-		}
-		if debugNet {
-			log.Println("kodo.List:", dir, "hasNext:", hasNext, "items:", len(ret.Items), "commonPrefixes:", ret.CommonPrefixes)
-		}
+	err = b.listPages(ctx, dir, "/", func(ret *kodo.ListFilesRet) error {
 		for _, item := range ret.Items {
 			key := item.Key
 			name := key[len(dir):]
@@ -122,12 +264,62 @@ func (b *Bucket) ReaddirContext(ctx context.Context, dir string) (fis []fs.FileI
 			name := key[len(dir) : len(key)-1]
 			fis = append(fis, xfs.NewDirInfo(name))
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// listResumeMax is the max number of consecutive failures of listing a page.
+const listResumeMax = 5
+
+// listPages lists all keys with `prefix` page by page. Requests of a page are retried (and
+// failed over to backup hosts) by the client, and if a page still fails with a transient
+// error, the listing resumes from the marker of the last good page after a backoff, instead
+// of starting over.
+func (b *Bucket) listPages(ctx context.Context, prefix, delimiter string, fn func(ret *kodo.ListFilesRet) error) error {
+	m, bucket := b.m, b.bucket
+	opts := []kodo.ListInputOption{
+		kodo.ListInputOptionsPrefix(prefix),
+		kodo.ListInputOptionsDelimiter(delimiter),
+		kodo.ListInputOptionsLimit(1000),
+	}
+	marker, failures := "", 0
+	var waiter *clientv2.BackoffWaiter
+	for {
+		ret, hasNext, err := m.ListFilesWithContext(ctx, bucket, append(opts, kodo.ListInputOptionsMarker(marker))...)
+		if err != nil {
+			if failures++; failures > listResumeMax || !clientv2.IsErrorRetryable(err) {
+				return err
+			}
+			if failures == 1 {
+				waiter = clientv2.DefaultBackoff().NewWaiter()
+			}
+			if l := b.Logger(); l.Enabled(LogWarn) {
+				l.Log(LogWarn, "kodo.List: resume", "prefix", prefix, "marker", marker, "err", RedactError(err))
+			}
+			if ok, sErr := waiter.Wait(ctx, nil, err); !ok {
+				return err
+			} else if sErr != nil {
+				return sErr
+			}
+			continue
+		}
+		failures = 0
+		if l := b.Logger(); l.Enabled(LogDebug) {
+			l.Log(LogDebug, "kodo.List", "prefix", prefix, "hasNext", hasNext, "items", len(ret.Items),
+				"commonPrefixes", len(ret.CommonPrefixes))
+		}
+		if err = fn(ret); err != nil {
+			return err
+		}
 		if !hasNext {
-			break
+			return nil
 		}
 		marker = ret.Marker
 	}
-	return
 }
 
 func fromPutTime(putTime int64) time.Time {
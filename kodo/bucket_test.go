@@ -0,0 +1,32 @@
+package kodo
+
+import (
+	"testing"
+)
+
+func TestSub(t *testing.T) {
+	b := NewCredentials("ak", "sk").NewBucket("bkt")
+	sub := b.Sub("/team/a/").Sub("../b")
+	if root := sub.Root(); root != "team/a/b" {
+		t.Fatal("Root:", root)
+	}
+	cases := []struct {
+		name, key, dirKey string
+	}{
+		{"/", "team/a/b/", "team/a/b/"},
+		{"/x.txt", "team/a/b/x.txt", "team/a/b/x.txt/"},
+		{"../../../etc/passwd", "team/a/b/etc/passwd", "team/a/b/etc/passwd/"},
+		{"/dir/../../y", "team/a/b/y", "team/a/b/y/"},
+	}
+	for _, c := range cases {
+		if key := sub.key(c.name); key != c.key {
+			t.Errorf("key(%q): got %q, want %q", c.name, key, c.key)
+		}
+		if key := sub.dirKey(c.name); key != c.dirKey {
+			t.Errorf("dirKey(%q): got %q, want %q", c.name, key, c.dirKey)
+		}
+	}
+	if key := b.dirKey("/"); key != "" {
+		t.Fatal("dirKey of bucket root:", key)
+	}
+}
@@ -0,0 +1,34 @@
+package kodo
+
+import (
+	"context"
+	"errors"
+
+	clientv1 "github.com/xushiwei/kodofs/internal/kodo/client"
+	kodoreqid "github.com/xushiwei/kodofs/internal/kodo/reqid"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// ErrorInfo is an error returned by kodo services. It carries the X-Reqid and X-Log of the
+// response, the host and the HTTP status code, which Qiniu support needs to track requests.
+// Use errors.As to get it from errors of Bucket methods.
+type ErrorInfo = clientv1.ErrorInfo
+
+// WithReqid returns a context which sends `reqid` as the X-Reqid header of requests to kodo
+// services, to correlate them with logs of the caller.
+func WithReqid(ctx context.Context, reqid string) context.Context {
+	return kodoreqid.WithReqid(ctx, reqid)
+}
+
+// Reqid returns the X-Reqid of the response which causes `err`, or "" if err isn't caused
+// by an error response of kodo services.
+func Reqid(err error) string {
+	var e *ErrorInfo
+	if errors.As(err, &e) {
+		return e.Reqid
+	}
+	return ""
+}
+
+// -----------------------------------------------------------------------------------------
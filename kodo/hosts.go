@@ -0,0 +1,51 @@
+package kodo
+
+import (
+	"github.com/xushiwei/kodofs/internal/kodo/hostprovider"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// HostStrategy is the strategy to choose a host among the available hosts of a service
+// (up, rs, rsf, io, etc).
+type HostStrategy = hostprovider.Strategy
+
+const (
+	// HostFirst always chooses the first available host, and switches to backup hosts only
+	// after failures. It is the default strategy.
+	HostFirst = hostprovider.StrategyFirst
+
+	// HostRoundRobin chooses available hosts in turn.
+	HostRoundRobin = hostprovider.StrategyRoundRobin
+
+	// HostLatency chooses available hosts randomly, weighted by the inverse of their
+	// exponentially weighted moving average latencies.
+	HostLatency = hostprovider.StrategyEWMA
+)
+
+// FrozenHost is a host which is skipped after failures until a time (see Bucket.FrozenHosts).
+type FrozenHost = hostprovider.FrozenHost
+
+// FrozenHosts returns the frozen hosts of the bucket sorted by their expiry times, for
+// debugging. A host is frozen after requests to it fail, and is unfrozen after the expiry
+// time or a successful request.
+func (b *Bucket) FrozenHosts() []FrozenHost {
+	return b.m.FrozenHosts()
+}
+
+// HostProber checks the health of hosts actively. Hosts which fail the check are skipped
+// until they pass it again. A HostProber can be shared by buckets (see Options.HostProber),
+// and should be closed when it isn't used any more.
+type HostProber = hostprovider.Prober
+
+// HostProberConfig represents the options of a HostProber, such as the probing interval.
+// By default, a host passes the check if a tcp connection to it can be established, on the
+// port of its scheme (80 for http, 443 for https) unless the host has a port.
+type HostProberConfig = hostprovider.ProberConfig
+
+// NewHostProber creates a HostProber. Zero values of `config` mean defaults.
+func NewHostProber(config HostProberConfig) *HostProber {
+	return hostprovider.NewProber(config)
+}
+
+// -----------------------------------------------------------------------------------------
@@ -7,6 +7,7 @@ import (
 
 	"github.com/xushiwei/kodofs/internal/kodo"
 	"github.com/xushiwei/kodofs/internal/kodo/auth"
+	clientv1 "github.com/xushiwei/kodofs/internal/kodo/client"
 )
 
 // -----------------------------------------------------------------------------------------
@@ -19,14 +20,18 @@ func NewCredentials(accessKey, secretKey string) *Credentials {
 
 func (mac *Credentials) Upload(ctx context.Context, bucket, name string, r io.Reader, fsize int64) (err error) {
 	name = strings.TrimPrefix(name, "/")
+	return upload(ctx, (*auth.Credentials)(mac), nil, nil, bucket, name, r, fsize)
+}
+
+func upload(ctx context.Context, mac *auth.Credentials, cfg *kodo.Config, clt *clientv1.Client, bucket, key string, r io.Reader, fsize int64) (err error) {
 	putPolicy := kodo.PutPolicy{
-		Scope: bucket + ":" + name,
+		Scope: bucket + ":" + key,
 	}
-	upToken := putPolicy.UploadToken((*auth.Credentials)(mac))
+	upToken := putPolicy.UploadToken(mac)
 
 	var ret kodo.PutRet
-	formUploader := kodo.NewFormUploaderEx(nil, nil)
-	return formUploader.Put(ctx, &ret, upToken, name, r, fsize, nil)
+	formUploader := kodo.NewFormUploaderEx(cfg, clt)
+	return formUploader.Put(ctx, &ret, upToken, key, r, fsize, nil)
 }
 
 // -----------------------------------------------------------------------------------------
@@ -0,0 +1,87 @@
+package kodo
+
+import (
+	"io"
+	stdlog "log"
+
+	"github.com/xushiwei/kodofs/internal/kodo/log"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// Logger is a leveled logger with key-value fields, eg.
+//
+//	logger.Log(kodo.LogDebug, "kodo: http request", "method", "GET", "status", 200)
+//
+// Implement it to plug kodo logs into your logging system. Its methods are called
+// concurrently. Authorization headers, upload tokens and access keys are redacted from
+// the logs.
+type Logger = log.FieldLogger
+
+// LogLevel represents the level of a log.
+type LogLevel = log.LogLevel
+
+const (
+	LogDebug = log.LogDebug
+	LogInfo  = log.LogInfo
+	LogWarn  = log.LogWarn
+	LogError = log.LogError
+)
+
+// NopLogger discards all logs.
+var NopLogger Logger = log.Nop
+
+// NewStdLogger returns a Logger that writes logs not lower than `level` to w, in the
+// format of "[D] msg key=value ...".
+func NewStdLogger(w io.Writer, level LogLevel) Logger {
+	return log.NewFieldLogger(stdlog.New(w, "", stdlog.LstdFlags), level)
+}
+
+var debugLogger = log.NewFieldLogger(stdlog.Default(), LogDebug)
+
+// DefaultLogger returns the logger of buckets without Options.Logger: it writes debug
+// logs to the standard logger if SetDebug enables DbgFlagNetwork, or discards logs.
+func DefaultLogger() Logger {
+	if debugNet {
+		return debugLogger
+	}
+	return NopLogger
+}
+
+// defaultLogger delegates to DefaultLogger at logging time, so that SetDebug takes effect
+// on opened buckets.
+type defaultLogger struct{}
+
+func (defaultLogger) Enabled(level LogLevel) bool {
+	return DefaultLogger().Enabled(level)
+}
+
+func (defaultLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	DefaultLogger().Log(level, msg, keyvals...)
+}
+
+func (p *Options) logger() Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	return defaultLogger{}
+}
+
+// RedactError returns the message of err with credentials (eg. tokens of private download
+// urls) redacted, to log it. It returns "" if err is nil.
+func RedactError(err error) string {
+	if err == nil {
+		return ""
+	}
+	return log.Redact(err.Error())
+}
+
+// Logger returns the logger of the bucket.
+func (b *Bucket) Logger() Logger {
+	if b.logger != nil {
+		return b.logger
+	}
+	return DefaultLogger()
+}
+
+// -----------------------------------------------------------------------------------------
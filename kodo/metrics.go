@@ -0,0 +1,14 @@
+package kodo
+
+import (
+	"github.com/xushiwei/kodofs/internal/kodo/clientv2"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// Metrics collects metrics of requests to kodo services: request counts, status codes and
+// latencies (each retry is a request), retries, host freezes, and bytes sent and received,
+// labeled by API classes and hosts. Its methods are called concurrently and shouldn't block.
+type Metrics = clientv2.Metrics
+
+// -----------------------------------------------------------------------------------------
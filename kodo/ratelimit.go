@@ -0,0 +1,39 @@
+package kodo
+
+import (
+	"github.com/xushiwei/kodofs/internal/kodo/clientv2"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// RateLimiter limits the request rates of kodo API classes (list, upload, rs and io), and
+// the byte rates of reading responses and writing requests. It can be shared by buckets
+// (see Options.RateLimiter) to limit their total rates, eg. to keep a bulk job from
+// starving the serving path in the same process.
+type RateLimiter = clientv2.RateLimiter
+
+// RateLimitConfig represents the options of a RateLimiter.
+type RateLimitConfig = clientv2.RateLimitConfig
+
+// Rate is a rate limit: Limit events (requests or bytes) per second, with bursts of at most
+// Burst events (default: Limit).
+type Rate = clientv2.Rate
+
+// APIClass is the class of a kodo API, used to limit request rates and collect metrics by
+// classes.
+type APIClass = clientv2.APIClass
+
+const (
+	APIClassList   = clientv2.APIClassList
+	APIClassUpload = clientv2.APIClassUpload
+	APIClassRs     = clientv2.APIClassRs
+	APIClassIo     = clientv2.APIClassIo
+	APIClassUc     = clientv2.APIClassUc
+)
+
+// NewRateLimiter creates a RateLimiter.
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	return clientv2.NewRateLimiter(config)
+}
+
+// -----------------------------------------------------------------------------------------
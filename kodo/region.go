@@ -0,0 +1,149 @@
+package kodo
+
+import (
+	"strings"
+
+	"github.com/xushiwei/kodofs/internal/kodo"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// Endpoints represents hosts of a kodo service: the main hosts followed by backup hosts,
+// which are switched to in order when requests fail.
+type Endpoints struct {
+	// Hosts are hosts of the service without schemes, eg. "rs.example.com:8080".
+	Hosts []string
+
+	// Scheme specifies the scheme of the hosts, "http" or "https". If it is empty,
+	// Options.UseHTTPS decides the scheme.
+	Scheme string
+}
+
+// Region represents hosts of kodo services of a region, eg. a private-cloud deployment
+// whose hosts differ from the public cloud (see Options.StaticRegion).
+type Region struct {
+	Up  Endpoints // up service to upload files
+	Rs  Endpoints // rs service to stat and delete files
+	Rsf Endpoints // rsf service to list files
+	Api Endpoints // api service
+	Io  Endpoints // io service to download files
+}
+
+// urls returns the hosts with schemes. Hosts which already have schemes are kept.
+func (p *Endpoints) urls(useHTTPS bool) []string {
+	scheme := p.Scheme
+	if scheme == "" {
+		scheme = "http"
+		if useHTTPS {
+			scheme = "https"
+		}
+	}
+	ret := make([]string, len(p.Hosts))
+	for i, host := range p.Hosts {
+		if strings.Contains(host, "://") {
+			ret[i] = host
+		} else {
+			ret[i] = scheme + "://" + host
+		}
+	}
+	return ret
+}
+
+// Regions is the table of known public-cloud regions by their ids, eg. "z0". A bucket
+// pinned to a region in the table (see Options.Region) uses its hosts directly without
+// querying the uc service. The table can be modified (eg. to add regions or override hosts)
+// before buckets are opened.
+var Regions = map[string]*Region{
+	"z0":             publicRegion("z0"),             // East China (Zhejiang)
+	"cn-east-2":      publicRegion("cn-east-2"),      // East China (Zhejiang 2)
+	"z1":             publicRegion("z1"),             // North China (Hebei)
+	"z2":             publicRegion("z2"),             // South China (Guangdong)
+	"na0":            publicRegion("na0"),            // North America (Los Angeles)
+	"as0":            publicRegion("as0"),            // Asia Pacific (Singapore)
+	"ap-northeast-1": publicRegion("ap-northeast-1"), // Asia Pacific (Seoul)
+	"ap-southeast-2": publicRegion("ap-southeast-2"), // Asia Pacific (Hanoi)
+	"ap-southeast-3": publicRegion("ap-southeast-3"), // Asia Pacific (Ho Chi Minh)
+}
+
+// publicRegion returns hosts of a public-cloud region. The accelerated up host is the backup
+// of the source up host.
+func publicRegion(id string) *Region {
+	if id == "z0" {
+		return &Region{
+			Up:  Endpoints{Hosts: []string{"up.qiniup.com", "upload.qiniup.com"}},
+			Rs:  Endpoints{Hosts: []string{"rs-z0.qiniuapi.com"}},
+			Rsf: Endpoints{Hosts: []string{"rsf-z0.qiniuapi.com"}},
+			Api: Endpoints{Hosts: []string{"api.qiniuapi.com"}},
+			Io:  Endpoints{Hosts: []string{"iovip.qiniuio.com"}},
+		}
+	}
+	return &Region{
+		Up:  Endpoints{Hosts: []string{"up-" + id + ".qiniup.com", "upload-" + id + ".qiniup.com"}},
+		Rs:  Endpoints{Hosts: []string{"rs-" + id + ".qiniuapi.com"}},
+		Rsf: Endpoints{Hosts: []string{"rsf-" + id + ".qiniuapi.com"}},
+		Api: Endpoints{Hosts: []string{"api-" + id + ".qiniuapi.com"}},
+		Io:  Endpoints{Hosts: []string{"iovip-" + id + ".qiniuio.com"}},
+	}
+}
+
+func first(hosts []string) string {
+	if len(hosts) > 0 {
+		return hosts[0]
+	}
+	return ""
+}
+
+func (p *Region) toInternal(useHTTPS bool) *kodo.Region {
+	up, rs, rsf := p.Up.urls(useHTTPS), p.Rs.urls(useHTTPS), p.Rsf.urls(useHTTPS)
+	api, io := p.Api.urls(useHTTPS), p.Io.urls(useHTTPS)
+	return &kodo.Region{
+		SrcUpHosts: up,
+		CdnUpHosts: up,
+		RsHost:     first(rs),
+		RsfHost:    first(rsf),
+		ApiHost:    first(api),
+		IovipHost:  first(io),
+		IoSrcHost:  first(io),
+		RsHosts:    rs,
+		RsfHosts:   rsf,
+		ApiHosts:   api,
+		IovipHosts: io,
+	}
+}
+
+// RegionCache caches regions of buckets discovered by querying the uc service. Its methods
+// are called concurrently. A cached region is deleted when requests to the bucket fail
+// because the bucket doesn't exist or hosts are unreachable, so it's queried again.
+type RegionCache = kodo.RegionCache
+
+// FileRegionCacheOptions represents the options of NewFileRegionCache: the path of the cache
+// file (default: DefaultRegionCachePath()) and permissions of the file (default: 0600) and
+// its directory (default: 0700).
+type FileRegionCacheOptions = kodo.FileRegionCacheOptions
+
+// NopRegionCache doesn't cache regions: the uc service is queried every time.
+var NopRegionCache = kodo.NopRegionCache
+
+// DefaultRegionCache is the RegionCache of buckets without Options.RegionCache. It keeps
+// regions in memory; use NewFileRegionCache to share them between processes.
+var DefaultRegionCache = kodo.DefaultRegionCache
+
+// DefaultRegionCachePath returns the default path of the file of NewFileRegionCache, which is
+// in the cache directory of the current user ($XDG_CACHE_HOME/qiniu-golang-sdk on Linux). It
+// returns "" if there is no such directory, and then regions are only kept in memory.
+func DefaultRegionCachePath() string {
+	return kodo.DefaultRegionCachePath()
+}
+
+// NewMemoryRegionCache returns a RegionCache in memory.
+func NewMemoryRegionCache() RegionCache {
+	return kodo.NewMemoryRegionCache()
+}
+
+// NewFileRegionCache returns a RegionCache persisted to a file, which is shared by processes.
+// The file is read at the first use.
+func NewFileRegionCache(opts FileRegionCacheOptions) RegionCache {
+	return kodo.NewFileRegionCache(opts)
+}
+
+// -----------------------------------------------------------------------------------------
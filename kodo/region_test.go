@@ -0,0 +1,70 @@
+package kodo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStaticRegion(t *testing.T) {
+	cases := []struct {
+		ep       Endpoints
+		useHTTPS bool
+		urls     string
+	}{
+		{Endpoints{Hosts: []string{"rs.example.com", "rs2.example.com:8080"}}, true, "https://rs.example.com,https://rs2.example.com:8080"},
+		{Endpoints{Hosts: []string{"rs.example.com"}}, false, "http://rs.example.com"},
+		{Endpoints{Hosts: []string{"rs.example.com"}, Scheme: "http"}, true, "http://rs.example.com"},
+		{Endpoints{Hosts: []string{"https://rs.example.com", "rs2.example.com"}, Scheme: "http"}, false, "https://rs.example.com,http://rs2.example.com"},
+		{Endpoints{}, true, ""},
+	}
+	for _, c := range cases {
+		if urls := strings.Join(c.ep.urls(c.useHTTPS), ","); urls != c.urls {
+			t.Errorf("urls(%v, %v) = %s", c.ep, c.useHTTPS, urls)
+		}
+	}
+
+	r := &Region{
+		Up: Endpoints{Hosts: []string{"up1", "up2"}},
+		Rs: Endpoints{Hosts: []string{"rs"}, Scheme: "http"},
+		Io: Endpoints{Hosts: []string{"io1", "io2"}},
+	}
+	ir := r.toInternal(true)
+	if strings.Join(ir.SrcUpHosts, ",") != "https://up1,https://up2" || ir.RsHost != "http://rs" ||
+		ir.RsfHost != "" || len(ir.RsfHosts) != 0 || ir.IovipHost != "https://io1" || len(ir.IovipHosts) != 2 {
+		t.Fatalf("toInternal: %+v", ir)
+	}
+}
+
+func TestRegions(t *testing.T) {
+	for _, id := range []string{"z0", "cn-east-2", "z1", "z2", "na0", "as0", "ap-northeast-1", "ap-southeast-2", "ap-southeast-3"} {
+		r, ok := Regions[id]
+		if !ok {
+			t.Fatal("Regions: no", id)
+		}
+		for _, ep := range []Endpoints{r.Up, r.Rs, r.Rsf, r.Api, r.Io} {
+			if len(ep.Hosts) == 0 || strings.Contains(ep.Hosts[0], "://") {
+				t.Fatalf("Regions[%s]: %+v", id, r)
+			}
+		}
+		if id != "z0" && !strings.Contains(r.Rs.Hosts[0], id) {
+			t.Fatalf("Regions[%s]: rs host %s", id, r.Rs.Hosts[0])
+		}
+	}
+	if r := Regions["z0"]; len(r.Up.Hosts) != 2 || r.Up.Hosts[1] != "upload.qiniup.com" { // accelerated up host as the backup
+		t.Fatal("Regions[z0]:", r.Up.Hosts)
+	}
+}
+
+func TestRegionOption(t *testing.T) {
+	Regions["test"] = &Region{Up: Endpoints{Hosts: []string{"up"}}, Rs: Endpoints{Hosts: []string{"rs"}}}
+	defer delete(Regions, "test")
+
+	b := NewCredentials("ak", "sk").NewBucketEx("bkt", &Options{Region: "test", UseHTTPS: true})
+	if r := b.m.Cfg.Region; r == nil || !reflect.DeepEqual(r, Regions["test"].toInternal(true)) {
+		t.Fatalf("Region: %+v", r)
+	}
+	if b = NewCredentials("ak", "sk").NewBucketEx("bkt", &Options{Region: "unknown"}); b.m.Cfg.Region != nil {
+		t.Fatal("Region: unknown region is used")
+	}
+}
@@ -0,0 +1,50 @@
+package kodo
+
+import (
+	"context"
+
+	"github.com/xushiwei/kodofs/internal/kodo/clientv2"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// Tracer creates spans of bucket operations, to bridge them to a tracing system such as
+// OpenTelemetry. Each operation (eg. "kodo.Open", "kodo.ReaddirContext", "kodo.Upload" and
+// "kodo.QueryRegion") is a span, and each HTTP request of it (every retry is a request) is
+// a child span "kodo.HTTP" with attributes of the status code, reqid and network timings
+// (see the Attr constants). Its methods are called concurrently.
+type Tracer = clientv2.Tracer
+
+// Span is a span created by a Tracer.
+type Span = clientv2.Span
+
+// Attributes of "kodo.HTTP" spans.
+const (
+	AttrAPIClass   = clientv2.AttrAPIClass
+	AttrMethod     = clientv2.AttrMethod
+	AttrHost       = clientv2.AttrHost
+	AttrStatusCode = clientv2.AttrStatusCode
+	AttrReqid      = clientv2.AttrReqid
+	AttrConnReused = clientv2.AttrConnReused
+	AttrDNS        = clientv2.AttrDNS       // time.Duration of the DNS lookup
+	AttrConnect    = clientv2.AttrConnect   // time.Duration of the tcp connecting
+	AttrTLS        = clientv2.AttrTLS       // time.Duration of the TLS handshake
+	AttrFirstByte  = clientv2.AttrFirstByte // time.Duration from the request written to the first response byte
+)
+
+// Attributes of operation spans.
+const (
+	AttrBucket = "kodo.bucket"
+	AttrKey    = "kodo.key"
+)
+
+// startSpan starts the span of the operation `op` on the file or directory `name`. It
+// returns a noop span if Options.Tracer is nil.
+func (b *Bucket) startSpan(ctx context.Context, op, name string) (context.Context, Span) {
+	ctx, span := clientv2.StartSpan(ctx, b.m.Cfg.Tracer, "kodo."+op)
+	span.SetAttribute(AttrBucket, b.bucket)
+	span.SetAttribute(AttrKey, b.key(name))
+	return ctx, span
+}
+
+// -----------------------------------------------------------------------------------------
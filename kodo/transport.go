@@ -0,0 +1,102 @@
+package kodo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	clientv1 "github.com/xushiwei/kodofs/internal/kodo/client"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// TransportOptions represents the options of the http transport to access kodo services.
+// Zero values mean defaults of http.DefaultTransport.
+type TransportOptions struct {
+	// DialTimeout is the max time to establish a tcp connection.
+	DialTimeout time.Duration
+
+	// TLSHandshakeTimeout is the max time to wait for a TLS handshake.
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout is the max time to wait for response headers after a request
+	// is written. It doesn't limit the time to read the response body.
+	ResponseHeaderTimeout time.Duration
+
+	// IdleConnTimeout is the max time an idle connection is kept in the pool.
+	IdleConnTimeout time.Duration
+
+	// Proxy returns the proxy of a request, eg. http.ProxyURL(u). If it is nil, proxies are
+	// read from the environment (see http.ProxyFromEnvironment).
+	Proxy func(req *http.Request) (*url.URL, error)
+
+	// RootCAs is the set of root CAs to verify TLS certificates of servers. If it is nil,
+	// the system roots are used.
+	RootCAs *x509.CertPool
+
+	// MaxConnsPerHost limits the number of connections (active or idle) to a host. Zero
+	// means no limit.
+	MaxConnsPerHost int
+
+	// MaxIdleConnsPerHost is the max number of idle connections kept to a host.
+	MaxIdleConnsPerHost int
+
+	// Timeout limits the whole time of a request, including reading the response body.
+	// Note it limits downloads of large files too. Zero means no timeout.
+	Timeout time.Duration
+}
+
+// NewTransport creates a http transport with the options.
+func (p *TransportOptions) NewTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if p.DialTimeout > 0 {
+		dialer := &net.Dialer{Timeout: p.DialTimeout, KeepAlive: 30 * time.Second}
+		t.DialContext = dialer.DialContext
+	}
+	if p.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = p.TLSHandshakeTimeout
+	}
+	if p.ResponseHeaderTimeout > 0 {
+		t.ResponseHeaderTimeout = p.ResponseHeaderTimeout
+	}
+	if p.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = p.IdleConnTimeout
+	}
+	if p.Proxy != nil {
+		t.Proxy = p.Proxy
+	}
+	if p.RootCAs != nil {
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.RootCAs = p.RootCAs
+	}
+	if p.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = p.MaxConnsPerHost
+	}
+	if p.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = p.MaxIdleConnsPerHost
+	}
+	return t
+}
+
+// NewClient creates a http client with the options.
+func (p *TransportOptions) NewClient() *http.Client {
+	return &http.Client{Transport: p.NewTransport(), Timeout: p.Timeout}
+}
+
+// httpClient returns the client to access kodo services, or nil to use the default one.
+func (p *Options) httpClient() *clientv1.Client {
+	if p.Client != nil {
+		return &clientv1.Client{Client: p.Client}
+	}
+	if p.Transport != nil {
+		return &clientv1.Client{Client: p.Transport.NewClient()}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------------------
@@ -0,0 +1,45 @@
+// Package kodoerr defines errors of kodo services which can be tested by errors.Is.
+//
+// Errors returned by kodo buckets (list, stat, upload, download and management calls) match
+// these sentinel errors by their kodo error codes, eg.
+//
+//	if errors.Is(err, kodoerr.ErrNotFound) { ... }
+//
+// Sentinel errors also match the errors of io/fs: ErrNotFound and ErrNoSuchBucket match
+// fs.ErrNotExist, ErrExists matches fs.ErrExist, and ErrUnauthorized matches fs.ErrPermission.
+// Use errors.As with *kodo.ErrorInfo to get details such as the reqid of the response.
+package kodoerr
+
+import (
+	clientv1 "github.com/xushiwei/kodofs/internal/kodo/client"
+)
+
+// Kodo error codes.
+const (
+	CodeUnauthorized = clientv1.CodeUnauthorized // 401: bad credentials
+	CodeNotFound     = clientv1.CodeNotFound     // 612: no such file or directory
+	CodeExists       = clientv1.CodeExists       // 614: file exists
+	CodeNoSuchBucket = clientv1.CodeNoSuchBucket // 631: no such bucket
+	CodeRateLimited  = clientv1.CodeRateLimited  // 573: too many requests
+)
+
+var (
+	// ErrNotFound means a file doesn't exist (code 612, or HTTP 404 of downloads).
+	ErrNotFound = clientv1.ErrNotFound
+
+	// ErrExists means a file already exists (code 614).
+	ErrExists = clientv1.ErrExists
+
+	// ErrNoSuchBucket means a bucket doesn't exist (code 631).
+	ErrNoSuchBucket = clientv1.ErrNoSuchBucket
+
+	// ErrUnauthorized means credentials are bad or not permitted (HTTP 401 or 403).
+	ErrUnauthorized = clientv1.ErrUnauthorized
+
+	// ErrRateLimited means requests are rate limited by kodo (code 573, or HTTP 429).
+	ErrRateLimited = clientv1.ErrRateLimited
+
+	// ErrArchiveNotRestored means a file of archive storage is downloaded before it is
+	// restored.
+	ErrArchiveNotRestored = clientv1.ErrArchiveNotRestored
+)
@@ -0,0 +1,48 @@
+package kodoerr
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	clientv1 "github.com/xushiwei/kodofs/internal/kodo/client"
+)
+
+func TestIs(t *testing.T) {
+	cases := []struct {
+		code   int
+		msg    string
+		target error
+		fsErr  error
+	}{
+		{612, "no such file or directory", ErrNotFound, fs.ErrNotExist},
+		{404, "not found", ErrNotFound, fs.ErrNotExist},
+		{614, "file exists", ErrExists, fs.ErrExist},
+		{631, "no such bucket", ErrNoSuchBucket, fs.ErrNotExist},
+		{401, "bad token", ErrUnauthorized, fs.ErrPermission},
+		{403, "forbidden", ErrUnauthorized, fs.ErrPermission},
+		{573, "too many requests", ErrRateLimited, nil},
+		{429, "too many requests", ErrRateLimited, nil},
+		{400, "the file must be restored before downloading", ErrArchiveNotRestored, nil},
+		{500, "internal error", nil, nil},
+	}
+	sentinels := []error{ErrNotFound, ErrExists, ErrNoSuchBucket, ErrUnauthorized, ErrRateLimited, ErrArchiveNotRestored}
+	fsErrs := []error{fs.ErrNotExist, fs.ErrExist, fs.ErrPermission}
+	for _, c := range cases {
+		err := fmt.Errorf("stat: %w", &clientv1.ErrorInfo{Code: c.code, Err: c.msg})
+		for _, target := range sentinels {
+			if got := errors.Is(err, target); got != (target == c.target) {
+				t.Errorf("errors.Is(%d, %v) = %v", c.code, target, got)
+			}
+		}
+		for _, target := range fsErrs {
+			if got := errors.Is(err, target); got != (target == c.fsErr) {
+				t.Errorf("errors.Is(%d, %v) = %v", c.code, target, got)
+			}
+		}
+	}
+	if !errors.Is(ErrNotFound, fs.ErrNotExist) || errors.Is(ErrNotFound, ErrNoSuchBucket) {
+		t.Fatal("sentinel errors")
+	}
+}
@@ -2,28 +2,24 @@ package kodofs
 
 import (
 	"context"
+	"errors"
+	"io"
 	"io/fs"
-	"log"
 	"net/http"
-	"os"
 	"path"
 	"strings"
 
 	xfs "github.com/qiniu/x/http/fs"
+	"github.com/xushiwei/kodofs/backend"
 	"github.com/xushiwei/kodofs/kodo"
 )
 
-var (
-	debugNet bool
-)
-
 const (
 	DbgFlagNetwork = kodo.DbgFlagNetwork
 	DbgFlagAll     = kodo.DbgFlagAll
 )
 
 func SetDebug(dbgFlags int) {
-	debugNet = (dbgFlags & DbgFlagNetwork) != 0
 	kodo.SetDebug(dbgFlags)
 }
 
@@ -44,37 +40,115 @@ func simplePrepareOpen(name string) (ctx context.Context, opener xfs.HttpOpener)
 	return
 }
 
+func clientPrepareOpen(client *http.Client) PrepareOpen {
+	return func(name string) (ctx context.Context, opener xfs.HttpOpener) {
+		return context.Background(), xfs.HttpOpener{Client: client}
+	}
+}
+
 // -----------------------------------------------------------------------------------------
 
+// Bucket is a http.FileSystem of a storage Backend, which is a kodo bucket by default.
+// Files are downloaded from the download host of the bucket if it is specified, or from the
+// Backend directly if not.
 type Bucket struct {
-	bkt     *kodo.Bucket
+	be      backend.Backend
 	prepare PrepareOpen
 	host    string
+	lc      *listCache // nil if listings aren't cached
+	lcRoot  string     // root of this view in keys of lc
+}
+
+// Options represents the options of opening a kodofs Bucket object.
+type Options struct {
+	kodo.Options
+
+	// ListCache enables the in-memory directory listing cache if it isn't nil.
+	ListCache *ListCacheOptions
 }
 
 func (mac *Credentials) NewBucket(bucket string, host string, prepare PrepareOpen) *Bucket {
+	return mac.NewBucketEx(bucket, host, prepare, nil)
+}
+
+// NewBucketEx opens a kodofs Bucket object with options.
+func (mac *Credentials) NewBucketEx(bucket string, host string, prepare PrepareOpen, opts *Options) *Bucket {
+	if opts == nil {
+		opts = &Options{}
+	}
+	auth := (*kodo.Credentials)(mac)
+	bkt := auth.NewBucketEx(bucket, &opts.Options)
+	if prepare == nil && (opts.Client != nil || opts.Transport != nil) {
+		prepare = clientPrepareOpen(bkt.HTTPClient()) // download from `host` by the client too
+	}
+	ret := FromBackend(bkt, host, prepare)
+	if opts.ListCache != nil {
+		ret = ret.WithListCache(opts.ListCache)
+	}
+	return ret
+}
+
+// FromBackend creates a kodofs Bucket object of a storage Backend, such as backend.NewMemory()
+// or backend.NewLocal(dir). If `host` is empty, files are read from the Backend directly.
+func FromBackend(be backend.Backend, host string, prepare PrepareOpen) *Bucket {
 	if prepare == nil {
 		prepare = simplePrepareOpen
 	}
-	auth := (*kodo.Credentials)(mac)
-	bkt := auth.NewBucket(bucket)
 	host = strings.TrimSuffix(host, "/")
-	return &Bucket{bkt, prepare, host}
+	return &Bucket{be: be, prepare: prepare, host: host}
+}
+
+// Backend returns the storage Backend of the bucket.
+func (b *Bucket) Backend() backend.Backend {
+	return b.be
+}
+
+// Sub returns a view of the bucket rooted at `prefix` (relative to the root of b).
+// All operations of the returned Bucket are scoped to the prefix, and paths can't escape
+// it via "..".
+func (b *Bucket) Sub(prefix string) *Bucket {
+	ret := *b
+	if rel := backend.CleanKey(prefix); rel != "" {
+		if ret.host != "" {
+			ret.host += "/" + rel
+		}
+		ret.lcRoot += "/" + rel
+	}
+	if bkt, ok := b.be.(*kodo.Bucket); ok {
+		ret.be = bkt.Sub(prefix)
+	} else {
+		ret.be = backend.Sub(b.be, prefix)
+	}
+	return &ret
 }
 
 // Open implements net/http.FileSystem.Open (https://pkg.go.dev/net/http#FileSystem).
 func (b *Bucket) Open(name string) (f http.File, err error) {
+	name = path.Clean("/" + name) // don't escape the root of the bucket view
 	ctx, opener := b.prepare(name)
-	if name != "/" {
-		f, err = opener.Open(ctx, b.host+name)
-		if debugNet {
-			log.Println("kodofs.Open:", name, "err:", err)
+	listed := false
+	if b.lc != nil {
+		if e, ok := b.lc.get(b.lcKey(name)); ok {
+			if e.notFound {
+				return nil, fs.ErrNotExist
+			}
+			listed = true // it is a directory
+		}
+	}
+	if name != "/" && !listed {
+		if b.host != "" {
+			f, err = opener.Open(ctx, b.host+name)
+		} else {
+			f, err = openObject(ctx, b.be, name)
+		}
+		if l := b.logger(); l.Enabled(kodo.LogDebug) {
+			l.Log(kodo.LogDebug, "kodofs.Open", "name", name, "err", kodo.RedactError(err))
 		}
-		if err == nil || isIndexPage(name) || !os.IsNotExist(err) {
+		if err == nil || isIndexPage(name) || !errors.Is(err, fs.ErrNotExist) {
 			return
 		}
 	}
-	fis, err := b.bkt.ReaddirContext(ctx, name)
+	fis, err := b.ReaddirContext(ctx, name)
 	if err != nil {
 		return
 	}
@@ -82,22 +156,92 @@ func (b *Bucket) Open(name string) (f http.File, err error) {
 		return nil, fs.ErrNotExist
 	}
 	fname := path.Base(name)
-	return xfs.Dir(xfs.NewDirInfo(fname), fis), nil
+	return dirFile{xfs.Dir(xfs.NewDirInfo(fname), fis)}, nil
+}
+
+// dirFile is a directory http.File which reports IsDir, as cached file systems require.
+type dirFile struct {
+	http.File
+}
+
+func (p dirFile) IsDir() bool {
+	return true
 }
 
 func isIndexPage(name string) bool {
 	return strings.HasSuffix(name, "/index.html")
 }
 
+// StatContext returns information of the file `name` without downloading it.
+func (b *Bucket) StatContext(ctx context.Context, name string) (fi fs.FileInfo, err error) {
+	name = path.Clean("/" + name)
+	info, err := b.be.Stat(ctx, backend.CleanKey(name))
+	if err != nil {
+		return
+	}
+	ret := xfs.NewFileInfo(path.Base(name), info.Size)
+	ret.Mtime = info.PutTime
+	return ret, nil
+}
+
+// ReaddirContext reads the directory `dir`. Listings are cached if the list cache is enabled
+// (see WithListCache).
 func (b *Bucket) ReaddirContext(ctx context.Context, dir string) (fis []fs.FileInfo, err error) {
-	return b.bkt.ReaddirContext(ctx, dir)
+	if b.lc != nil {
+		return b.lc.readdir(ctx, b.lcKey(dir), func(ctx context.Context) ([]fs.FileInfo, error) {
+			return b.readdir(ctx, dir)
+		})
+	}
+	return b.readdir(ctx, dir)
+}
+
+func (b *Bucket) readdir(ctx context.Context, dir string) (fis []fs.FileInfo, err error) {
+	if r, ok := b.be.(readdirContexter); ok {
+		return r.ReaddirContext(ctx, dir)
+	}
+	return backend.ReaddirContext(ctx, b.be, dir)
+}
+
+type walkContexter interface {
+	WalkContext(ctx context.Context, dir string, fn backend.WalkFunc) (err error)
+}
+
+// WalkContext walks all files under the directory `dir`. It stops if `fn` returns an error,
+// and returns the error.
+func (b *Bucket) WalkContext(ctx context.Context, dir string, fn kodo.WalkFunc) (err error) {
+	if w, ok := b.be.(walkContexter); ok {
+		return w.WalkContext(ctx, dir, fn)
+	}
+	return backend.WalkContext(ctx, b.be, dir, fn)
+}
+
+// Upload uploads the content `r` (`fsize` bytes) as the file `name`.
+func (b *Bucket) Upload(ctx context.Context, name string, r io.Reader, fsize int64) (err error) {
+	err = b.be.Put(ctx, backend.CleanKey(name), r, fsize)
+	b.InvalidateListCache(name)
+	return
+}
+
+// Delete deletes the file `name`.
+func (b *Bucket) Delete(ctx context.Context, name string) (err error) {
+	err = b.be.Delete(ctx, backend.CleanKey(name))
+	b.InvalidateListCache(name)
+	return
+}
+
+// logger returns the logger of the kodo backend, or kodo.DefaultLogger for other backends.
+func (b *Bucket) logger() kodo.Logger {
+	if bkt, ok := b.be.(*kodo.Bucket); ok {
+		return bkt.Logger()
+	}
+	return kodo.DefaultLogger()
 }
 
 // -----------------------------------------------------------------------------------------
 
 func New(accessKey, secretKey string, bucket string, host string, prepare PrepareOpen) *Bucket {
-	if debugNet {
-		log.Println("kodofs.New:", bucket, host)
+	if l := kodo.DefaultLogger(); l.Enabled(kodo.LogDebug) {
+		l.Log(kodo.LogDebug, "kodofs.New", "bucket", bucket, "host", host)
 	}
 	return NewCredentials(accessKey, secretKey).NewBucket(bucket, host, prepare)
 }
@@ -2,10 +2,13 @@ package kodofs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/qiniu/x/http/fsx"
+	"github.com/xushiwei/kodofs/kodo"
 	"github.com/xushiwei/kodofs/kodoutil"
 )
 
@@ -19,18 +22,60 @@ func init() {
 	fsx.Register(Scheme, Open)
 }
 
-// Open a kodofs file system by url in form of "kodo:<bucketName>?<token>".
-func Open(ctx context.Context, url string) (_ http.FileSystem, _ fsx.Closer, err error) {
-	bucket, ak, sk, err := kodoutil.Parse(url)
+// CacheOpener opens a cached file system of `bkt` by cache params of the kodofs url `u`.
+type CacheOpener = func(ctx context.Context, u *kodoutil.URL, bkt *Bucket) (http.FileSystem, fsx.Closer, error)
+
+var (
+	cacheOpener CacheOpener
+)
+
+// RegisterCache registers the opener of urls with a `cache` param. It is called by package
+// github.com/xushiwei/kodofs/cached, so import the package to open such urls.
+func RegisterCache(open CacheOpener) {
+	cacheOpener = open
+}
+
+// Open a kodofs file system by url in form of "kodo:<bucketName>?<token>[&<param>=<value>...]".
+// See kodoutil.URL for supported params.
+func Open(ctx context.Context, url string) (fs http.FileSystem, _ fsx.Closer, err error) {
+	u, err := kodoutil.ParseURL(url)
 	if err != nil {
 		return
 	}
-	host, ok := hosts[bucket]
-	if !ok {
-		err = fmt.Errorf("host of bucket `%s` not found, please call kodofs.Register", bucket)
-		return
+	host := u.Host
+	if host == "" {
+		var ok bool
+		if host, ok = hosts[u.Bucket]; !ok {
+			err = fmt.Errorf("host of bucket `%s` not found, please call kodofs.Register", u.Bucket)
+			return
+		}
 	}
-	return New(ak, sk, bucket, host, nil), nil, nil
+	if !strings.Contains(host, "://") {
+		if u.UseHTTPS {
+			host = "https://" + host
+		} else {
+			host = "http://" + host
+		}
+	}
+	opts := &Options{
+		Options: kodo.Options{Region: u.Region, UseHTTPS: u.UseHTTPS},
+	}
+	bkt := NewCredentials(u.AccessKey, u.SecretKey).NewBucketEx(u.Bucket, host, nil, opts)
+	if u.Root != "" {
+		bkt = bkt.Sub(u.Root)
+	}
+	if u.Cache != "" {
+		if cacheOpener == nil {
+			err = errors.New("kodofs.Open: cache params require package github.com/xushiwei/kodofs/cached")
+			return
+		}
+		return cacheOpener(ctx, u, bkt)
+	}
+	return bkt, nopClose, nil
+}
+
+func nopClose() error {
+	return nil
 }
 
 // -----------------------------------------------------------------------------------------
@@ -0,0 +1,532 @@
+// Package kodotest provides a fake kodo server for testing code built on kodofs offline.
+package kodotest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xushiwei/kodofs/backend"
+	"github.com/xushiwei/kodofs/internal/kodo"
+	"github.com/xushiwei/kodofs/internal/kodo/auth"
+	xkodo "github.com/xushiwei/kodofs/kodo"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// Server is a fake kodo server which emulates these kodo APIs of one bucket:
+//   - uc: GET /v2/query
+//   - rsf: POST /list (with delimiter and marker paging)
+//   - up: POST / (form upload, with crc32 checking)
+//   - rs: POST /stat/<entry>, /delete/<entry>, /move/<src>/<dst>[/force/true]
+//   - io: GET /<key>?e=<deadline>&token=<token> (private download urls, with Range)
+//
+// All services are served by the same address, and requests are verified by signatures of
+// AccessKey and SecretKey.
+type Server struct {
+	*httptest.Server
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Backend   backend.Backend
+
+	// Aliases are backup hosts (eg. "localhost:<port>") of all services returned by the uc
+	// query, to test host switching. They should be addresses of the Server too.
+	Aliases []string
+
+	// Public makes io downloads not require signatures, like downloads of a public bucket
+	// (eg. by a kodofs Bucket with a download host).
+	Public bool
+}
+
+// NewServer starts a fake kodo Server for `bucket` backed by `be` (an in-memory backend if
+// it is nil). The caller should call Close when finished, to shut it down.
+func NewServer(bucket string, be backend.Backend) *Server {
+	if be == nil {
+		be = backend.NewMemory()
+	}
+	s := &Server{
+		Bucket:    bucket,
+		AccessKey: randomKey(),
+		SecretKey: randomKey(),
+		Backend:   be,
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Credentials returns credentials accepted by the Server.
+func (s *Server) Credentials() *xkodo.Credentials {
+	return xkodo.NewCredentials(s.AccessKey, s.SecretKey)
+}
+
+// Options returns options of opening a kodo Bucket which accesses the Server. The region
+// of the bucket is cached in memory.
+func (s *Server) Options() *xkodo.Options {
+	return &xkodo.Options{UcHosts: []string{s.URL}, RegionCache: xkodo.NewMemoryRegionCache()}
+}
+
+// Put stores `data` as the object `key` directly (without a form upload).
+func (s *Server) Put(key string, data []byte) error {
+	return s.Backend.Put(context.Background(), key, bytes.NewReader(data), int64(len(data)))
+}
+
+func randomKey() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// -----------------------------------------------------------------------------------------
+
+const (
+	codeBadToken     = 401
+	codeCrc32        = 406
+	codeNotFound     = 612
+	codeExists       = 614
+	codeNoSuchBucket = 631
+)
+
+type httpError struct {
+	code int
+	msg  string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+func errorf(code int, format string, args ...interface{}) error {
+	return &httpError{code, fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	reqid := req.Header.Get("X-Reqid") // echo the reqid of the client if any
+	if reqid == "" {
+		reqid = randomKey()
+	}
+	w.Header().Set("X-Reqid", reqid)
+	w.Header().Set("X-Log", "kodotest")
+	var ret interface{}
+	var err error
+	path := req.URL.Path
+	switch {
+	case path == "/v2/query":
+		ret, err = s.ucQuery(req)
+	case path == "/list":
+		ret, err = s.rsfList(req)
+	case strings.HasPrefix(path, "/stat/"):
+		ret, err = s.rsStat(req)
+	case strings.HasPrefix(path, "/delete/"):
+		err = s.rsDelete(req)
+	case strings.HasPrefix(path, "/move/"):
+		err = s.rsMove(req)
+	case path == "/" && req.Method == http.MethodPost:
+		ret, err = s.upload(req)
+	case req.Method == http.MethodGet || req.Method == http.MethodHead:
+		err = s.ioGet(w, req)
+		if err == nil {
+			return
+		}
+	default:
+		err = errorf(http.StatusMethodNotAllowed, "method not allowed")
+	}
+	if err != nil {
+		replyError(w, err)
+		return
+	}
+	replyJSON(w, http.StatusOK, ret)
+}
+
+func replyJSON(w http.ResponseWriter, code int, ret interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if ret != nil {
+		json.NewEncoder(w).Encode(ret)
+	}
+}
+
+func replyError(w http.ResponseWriter, err error) {
+	var e *httpError
+	if !errors.As(err, &e) {
+		if errors.Is(err, fs.ErrNotExist) {
+			e = &httpError{codeNotFound, "no such file or directory"}
+		} else {
+			e = &httpError{http.StatusInternalServerError, err.Error()}
+		}
+	}
+	replyJSON(w, e.code, map[string]string{"error": e.msg})
+}
+
+// -----------------------------------------------------------------------------------------
+
+func (s *Server) checkAuth(req *http.Request) error {
+	authz := req.Header.Get("Authorization")
+	mac := auth.New(s.AccessKey, s.SecretKey)
+	var want string
+	var err error
+	switch {
+	case strings.HasPrefix(authz, auth.AuthorizationPrefixQiniu):
+		want, err = mac.SignRequestV2(req)
+		want = auth.AuthorizationPrefixQiniu + want
+	case strings.HasPrefix(authz, auth.AuthorizationPrefixQBox):
+		want, err = mac.SignRequest(req)
+		want = auth.AuthorizationPrefixQBox + want
+	default:
+		return errorf(codeBadToken, "bad token")
+	}
+	if err != nil || !hmac.Equal([]byte(authz), []byte(want)) {
+		return errorf(codeBadToken, "bad token")
+	}
+	return nil
+}
+
+// checkDownload verifies a private download url "<url>?e=<deadline>&token=<ak>:<sign>",
+// where sign is the hmac-sha1 of "<url>?e=<deadline>" (including the scheme and host).
+func (s *Server) checkDownload(req *http.Request) error {
+	if s.Public {
+		return nil
+	}
+	rawurl := "http://" + req.Host + req.URL.RequestURI()
+	pos := strings.Index(rawurl, "&token=")
+	if pos < 0 {
+		return errorf(codeBadToken, "download token required")
+	}
+	deadline, err := strconv.ParseInt(req.URL.Query().Get("e"), 10, 64)
+	if err != nil || time.Now().Unix() > deadline {
+		return errorf(codeBadToken, "download token expired")
+	}
+	mac := auth.New(s.AccessKey, s.SecretKey)
+	if !hmac.Equal([]byte(rawurl[pos+len("&token="):]), []byte(mac.Sign([]byte(rawurl[:pos])))) {
+		return errorf(codeBadToken, "bad download token")
+	}
+	return nil
+}
+
+func (s *Server) checkBucket(bucket string) error {
+	if bucket != s.Bucket {
+		return errorf(codeNoSuchBucket, "no such bucket")
+	}
+	return nil
+}
+
+// parseEntry parses an encoded entry "<bucket>:<key>" of rs APIs.
+func (s *Server) parseEntry(encoded string) (key string, err error) {
+	b, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errorf(http.StatusBadRequest, "invalid entry")
+	}
+	bucket, key, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return "", errorf(http.StatusBadRequest, "invalid entry")
+	}
+	return key, s.checkBucket(bucket)
+}
+
+// -----------------------------------------------------------------------------------------
+
+func (s *Server) ucQuery(req *http.Request) (ret interface{}, err error) {
+	query := req.URL.Query()
+	if query.Get("ak") != s.AccessKey {
+		return nil, errorf(codeBadToken, "bad token")
+	}
+	if err = s.checkBucket(query.Get("bucket")); err != nil {
+		return
+	}
+	host := strings.TrimPrefix(s.URL, "http://")
+	info := map[string]kodo.UcQueryServerInfo{
+		"src": {Main: []string{host}, Backup: s.Aliases},
+	}
+	return map[string]interface{}{
+		"ttl":    86400,
+		"io":     info,
+		"io_src": info,
+		"up":     map[string]kodo.UcQueryServerInfo{"src": info["src"], "acc": info["src"]},
+		"rs":     info,
+		"rsf":    info,
+		"api":    info,
+	}, nil
+}
+
+type listItem struct {
+	Key      string `json:"key"`
+	PutTime  int64  `json:"putTime"`
+	Hash     string `json:"hash"`
+	Fsize    int64  `json:"fsize"`
+	MimeType string `json:"mimeType"`
+}
+
+func newListItem(obj *backend.ObjectInfo) listItem {
+	return listItem{obj.Key, toPutTime(obj.PutTime), obj.Hash, obj.Size, obj.MimeType}
+}
+
+func toPutTime(t time.Time) int64 {
+	return t.UnixNano() / 100
+}
+
+func (s *Server) rsfList(req *http.Request) (ret interface{}, err error) {
+	if err = s.checkAuth(req); err != nil {
+		return
+	}
+	query := req.URL.Query()
+	if err = s.checkBucket(query.Get("bucket")); err != nil {
+		return
+	}
+	limit := 1000
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > 1000 {
+			return nil, errorf(http.StatusBadRequest, "invalid limit")
+		}
+	}
+	r, err := s.Backend.List(req.Context(), query.Get("prefix"), query.Get("delimiter"), query.Get("marker"), limit)
+	if err != nil {
+		return
+	}
+	items := make([]listItem, len(r.Items))
+	for i := range r.Items {
+		items[i] = newListItem(&r.Items[i])
+	}
+	return map[string]interface{}{
+		"marker":         r.Marker,
+		"items":          items,
+		"commonPrefixes": r.CommonPrefixes,
+	}, nil
+}
+
+func (s *Server) rsStat(req *http.Request) (ret interface{}, err error) {
+	if err = s.checkAuth(req); err != nil {
+		return
+	}
+	key, err := s.parseEntry(strings.TrimPrefix(req.URL.Path, "/stat/"))
+	if err != nil {
+		return
+	}
+	obj, err := s.Backend.Stat(req.Context(), key)
+	if err != nil {
+		return
+	}
+	return map[string]interface{}{
+		"fsize":    obj.Size,
+		"hash":     obj.Hash,
+		"mimeType": obj.MimeType,
+		"putTime":  toPutTime(obj.PutTime),
+	}, nil
+}
+
+func (s *Server) rsDelete(req *http.Request) (err error) {
+	if err = s.checkAuth(req); err != nil {
+		return
+	}
+	key, err := s.parseEntry(strings.TrimPrefix(req.URL.Path, "/delete/"))
+	if err != nil {
+		return
+	}
+	return s.Backend.Delete(req.Context(), key)
+}
+
+func (s *Server) rsMove(req *http.Request) (err error) {
+	if err = s.checkAuth(req); err != nil {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/move/"), "/")
+	if len(parts) != 2 && !(len(parts) == 4 && parts[2] == "force") {
+		return errorf(http.StatusBadRequest, "invalid move command")
+	}
+	src, err := s.parseEntry(parts[0])
+	if err != nil {
+		return
+	}
+	dest, err := s.parseEntry(parts[1])
+	if err != nil {
+		return
+	}
+	ctx := req.Context()
+	force := len(parts) == 4 && parts[3] == "true"
+	if _, e := s.Backend.Stat(ctx, dest); e == nil && !force {
+		return errorf(codeExists, "file exists")
+	}
+	r, err := s.Backend.Open(ctx, src, 0, -1)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	if err = s.Backend.Put(ctx, dest, r, -1); err != nil {
+		return
+	}
+	return s.Backend.Delete(ctx, src)
+}
+
+// -----------------------------------------------------------------------------------------
+
+func (s *Server) checkUpToken(token, key string) (insertOnly bool, err error) {
+	parts := strings.Split(token, ":")
+	if len(parts) != 3 || parts[0] != s.AccessKey {
+		return false, errorf(codeBadToken, "bad token")
+	}
+	mac := auth.New(s.AccessKey, s.SecretKey)
+	if !hmac.Equal([]byte(mac.Sign([]byte(parts[2]))), []byte(parts[0]+":"+parts[1])) {
+		return false, errorf(codeBadToken, "bad token")
+	}
+	b, err := base64.URLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, errorf(codeBadToken, "bad token")
+	}
+	var policy kodo.PutPolicy
+	if err = json.Unmarshal(b, &policy); err != nil {
+		return false, errorf(codeBadToken, "bad token")
+	}
+	if policy.Expires < uint64(time.Now().Unix()) {
+		return false, errorf(codeBadToken, "expired token")
+	}
+	bucket, scopeKey, hasKey := strings.Cut(policy.Scope, ":")
+	if err = s.checkBucket(bucket); err != nil {
+		return
+	}
+	if hasKey {
+		if policy.IsPrefixalScope != 0 {
+			if !strings.HasPrefix(key, scopeKey) {
+				return false, errorf(http.StatusForbidden, "key doesn't match with scope")
+			}
+			return true, nil
+		}
+		if key != scopeKey {
+			return false, errorf(http.StatusForbidden, "key doesn't match with scope")
+		}
+		return policy.InsertOnly != 0, nil
+	}
+	return true, nil
+}
+
+func (s *Server) upload(req *http.Request) (ret interface{}, err error) {
+	if err = req.ParseMultipartForm(32 << 20); err != nil {
+		return nil, errorf(http.StatusBadRequest, "invalid multipart form: %v", err)
+	}
+	key := req.FormValue("key")
+	insertOnly, err := s.checkUpToken(req.FormValue("token"), key)
+	if err != nil {
+		return
+	}
+	file, _, err := req.FormFile("file")
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "file not found")
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return
+	}
+	if v := req.FormValue("crc32"); v != "" {
+		crc, e := strconv.ParseUint(v, 10, 32)
+		if e != nil || uint32(crc) != crc32.ChecksumIEEE(data) {
+			return nil, errorf(codeCrc32, "crc32 not match")
+		}
+	}
+	ctx := req.Context()
+	if insertOnly {
+		if _, e := s.Backend.Stat(ctx, key); e == nil {
+			return nil, errorf(codeExists, "file exists")
+		}
+	}
+	if err = s.Backend.Put(ctx, key, bytes.NewReader(data), int64(len(data))); err != nil {
+		return
+	}
+	obj, err := s.Backend.Stat(ctx, key)
+	if err != nil {
+		return
+	}
+	return map[string]string{"hash": obj.Hash, "key": key}, nil
+}
+
+// -----------------------------------------------------------------------------------------
+
+func (s *Server) ioGet(w http.ResponseWriter, req *http.Request) (err error) {
+	if err = s.checkDownload(req); err != nil {
+		return
+	}
+	ctx := req.Context()
+	key := strings.TrimPrefix(req.URL.Path, "/")
+	obj, err := s.Backend.Stat(ctx, key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return errorf(http.StatusNotFound, "no such file or directory")
+		}
+		return
+	}
+	offset, length, code := int64(0), obj.Size, http.StatusOK
+	h := w.Header()
+	if rg := req.Header.Get("Range"); rg != "" {
+		var ok bool
+		if offset, length, ok = parseRange(rg, obj.Size); !ok {
+			h.Set("Content-Range", fmt.Sprintf("bytes */%d", obj.Size))
+			return errorf(http.StatusRequestedRangeNotSatisfiable, "invalid range")
+		}
+		h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, obj.Size))
+		code = http.StatusPartialContent
+	}
+	var body io.ReadCloser
+	if req.Method == http.MethodGet {
+		if body, err = s.Backend.Open(ctx, key, offset, length); err != nil {
+			return
+		}
+		defer body.Close()
+	}
+	h.Set("Content-Type", obj.MimeType)
+	h.Set("Content-Length", strconv.FormatInt(length, 10))
+	h.Set("Accept-Ranges", "bytes")
+	h.Set("ETag", strconv.Quote(obj.Hash))
+	h.Set("Last-Modified", obj.PutTime.UTC().Format(http.TimeFormat))
+	w.WriteHeader(code)
+	if body != nil {
+		io.Copy(w, body)
+	}
+	return nil
+}
+
+// parseRange parses a single range "bytes=<first>-[<last>]" or "bytes=-<suffixLength>".
+func parseRange(rg string, size int64) (offset, length int64, ok bool) {
+	spec := strings.TrimPrefix(rg, "bytes=")
+	first, last, found := strings.Cut(spec, "-")
+	if spec == rg || !found || strings.Contains(spec, ",") {
+		return
+	}
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, n, size > 0
+	}
+	offset, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || offset < 0 || offset >= size {
+		return
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < offset {
+			return
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return offset, end - offset + 1, true
+}
+
+// -----------------------------------------------------------------------------------------
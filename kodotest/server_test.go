@@ -0,0 +1,226 @@
+package kodotest_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xushiwei/kodofs"
+	xkodo "github.com/xushiwei/kodofs/kodo"
+	"github.com/xushiwei/kodofs/kodotest"
+)
+
+func TestServer(t *testing.T) {
+	s := kodotest.NewServer("bkt", nil)
+	defer s.Close()
+
+	ctx := context.Background()
+	bkt := s.Credentials().NewBucketEx("bkt", s.Options())
+	if err := bkt.Upload(ctx, "/site/index.html", strings.NewReader("hello"), 5); err != nil {
+		t.Fatal("Upload:", err)
+	}
+	for i := 0; i < 1200; i++ { // more than one page
+		s.Put(fmt.Sprintf("site/img/%04d.png", i), []byte("png"))
+	}
+
+	fis, err := bkt.ReaddirContext(ctx, "/site")
+	if err != nil {
+		t.Fatal("ReaddirContext:", err)
+	}
+	if len(fis) != 2 || fis[0].Name() != "index.html" || !fis[1].IsDir() || fis[1].Name() != "img" {
+		t.Fatal("ReaddirContext /site:", fis)
+	}
+	n := 0
+	err = bkt.Sub("site").WalkContext(ctx, "/img", func(path string, info fs.FileInfo, err error) error {
+		if !strings.HasPrefix(path, "/img/") {
+			t.Fatal("WalkContext:", path)
+		}
+		n++
+		return nil
+	})
+	if err != nil || n != 1200 {
+		t.Fatal("WalkContext:", n, err)
+	}
+	if err = bkt.Delete(ctx, "/site/img/0000.png"); err != nil {
+		t.Fatal("Delete:", err)
+	}
+	if err = bkt.Delete(ctx, "/site/img/0000.png"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("Delete:", err)
+	}
+
+	info, err := bkt.Stat(ctx, "site/index.html")
+	if err != nil || info.Size != 5 || info.Key != "site/index.html" {
+		t.Fatal("Stat:", info, err)
+	}
+	if _, err = bkt.Stat(ctx, "site/img/0000.png"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("Stat:", err)
+	}
+	rc, err := bkt.Sub("site").Open(ctx, "index.html", 1, 3)
+	if err != nil {
+		t.Fatal("Backend.Open:", err)
+	}
+	b, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(b) != "ell" {
+		t.Fatal("Backend.Open:", string(b))
+	}
+
+	s.Public = true // kodofs with a download host accesses a public bucket
+	opts := &kodofs.Options{Options: *s.Options()}
+	site := kodofs.NewCredentials(s.AccessKey, s.SecretKey).NewBucketEx("bkt", s.URL, nil, opts).Sub("site")
+	f, err := site.Open("/index.html")
+	if err != nil {
+		t.Fatal("Open:", err)
+	}
+	b, _ = io.ReadAll(f)
+	f.Close()
+	if string(b) != "hello" {
+		t.Fatal("Open /index.html:", string(b))
+	}
+	direct := kodofs.FromBackend(bkt, "", nil).Sub("site") // read files from the backend
+	if f, err = direct.Open("/index.html"); err != nil {
+		t.Fatal("FromBackend.Open:", err)
+	}
+	b, _ = io.ReadAll(f)
+	f.Close()
+	if string(b) != "hello" {
+		t.Fatal("FromBackend.Open /index.html:", string(b))
+	}
+	if _, err = site.Open("/notfound.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("Open notfound:", err)
+	}
+
+	req, _ := http.NewRequest("GET", s.URL+"/site/index.html", nil)
+	req.Header.Set("Range", "bytes=1-3")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("Range:", err)
+	}
+	b, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(b) != "ell" {
+		t.Fatal("Range:", resp.StatusCode, string(b))
+	}
+}
+
+func TestDownloadToken(t *testing.T) {
+	s := kodotest.NewServer("bkt", nil)
+	defer s.Close()
+
+	s.Put("a.txt", []byte("a"))
+	sign := func(rawurl, sk string) string {
+		h := hmac.New(sha1.New, []byte(sk))
+		h.Write([]byte(rawurl))
+		return rawurl + "&token=" + s.AccessKey + ":" + base64.URLEncoding.EncodeToString(h.Sum(nil))
+	}
+	future := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	past := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	cases := []struct {
+		url  string
+		code int
+	}{
+		{sign(s.URL+"/a.txt?e="+future, s.SecretKey), http.StatusOK},
+		{s.URL + "/a.txt", http.StatusUnauthorized},
+		{s.URL + "/a.txt?e=" + future, http.StatusUnauthorized},
+		{sign(s.URL+"/a.txt?e="+past, s.SecretKey), http.StatusUnauthorized},
+		{sign(s.URL+"/a.txt?e="+future, "bad-sk"), http.StatusUnauthorized},
+		{strings.Replace(sign(s.URL+"/b.txt?e="+future, s.SecretKey), "/b.txt", "/a.txt", 1), http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		resp, err := http.Get(c.url)
+		if err != nil {
+			t.Fatal("Get:", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.code {
+			t.Errorf("Get %s: got %d, want %d", c.url, resp.StatusCode, c.code)
+		}
+	}
+
+	// tokens sign the host a url is sent to, including aliases
+	alias := strings.Replace(s.URL, "127.0.0.1", "localhost", 1)
+	for _, c := range []struct {
+		url  string
+		code int
+	}{
+		{sign(alias+"/a.txt?e="+future, s.SecretKey), http.StatusOK},
+		{strings.Replace(sign(s.URL+"/a.txt?e="+future, s.SecretKey), s.URL, alias, 1), http.StatusUnauthorized},
+	} {
+		resp, err := http.Get(c.url)
+		if err != nil {
+			t.Fatal("Get:", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.code {
+			t.Errorf("Get %s: got %d, want %d", c.url, resp.StatusCode, c.code)
+		}
+	}
+	if _, err := xkodo.NewCredentials(s.AccessKey, "bad-sk").NewBucketEx("bkt", s.Options()).Open(context.Background(), "a.txt", 0, -1); !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("Open with bad credentials:", err)
+	}
+}
+
+func TestBadCredentials(t *testing.T) {
+	s := kodotest.NewServer("bkt", nil)
+	defer s.Close()
+
+	ctx := context.Background()
+	bkt := kodofs.NewCredentials(s.AccessKey, "bad-sk").NewBucketEx("bkt", s.URL, nil, &kodofs.Options{Options: *s.Options()})
+	if _, err := bkt.ReaddirContext(ctx, "/"); err == nil {
+		t.Fatal("ReaddirContext: no error")
+	}
+	if err := bkt.Upload(ctx, "a.txt", strings.NewReader("a"), 1); err == nil {
+		t.Fatal("Upload: no error")
+	}
+}
+
+type countingTransport struct {
+	n int32
+	t http.RoundTripper
+}
+
+func (p *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&p.n, 1)
+	return p.t.RoundTrip(req)
+}
+
+func TestClient(t *testing.T) {
+	s := kodotest.NewServer("bkt", nil)
+	defer s.Close()
+
+	s.Public = true // kodofs with a download host accesses a public bucket
+	ctx := context.Background()
+	tr := &countingTransport{t: (&xkodo.TransportOptions{ResponseHeaderTimeout: time.Second}).NewTransport()}
+	opts := &kodofs.Options{Options: *s.Options()}
+	opts.Client = &http.Client{Transport: tr}
+	bkt := kodofs.NewCredentials(s.AccessKey, s.SecretKey).NewBucketEx("bkt", s.URL, nil, opts)
+	if err := bkt.Upload(ctx, "/a.txt", strings.NewReader("a"), 1); err != nil {
+		t.Fatal("Upload:", err)
+	}
+	n := atomic.LoadInt32(&tr.n)
+	if n < 2 { // uc and up
+		t.Fatal("Upload: requests not sent by the client", n)
+	}
+	if _, err := bkt.ReaddirContext(ctx, "/"); err != nil {
+		t.Fatal("ReaddirContext:", err)
+	}
+	f, err := bkt.Open("/a.txt")
+	if err != nil {
+		t.Fatal("Open:", err)
+	}
+	f.Close()
+	if m := atomic.LoadInt32(&tr.n); m < n+2 { // rsf and io
+		t.Fatal("Open: requests not sent by the client", m-n)
+	}
+}
@@ -0,0 +1,46 @@
+package kodoutil
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+)
+
+const (
+	etagBlockSize = 4 * 1024 * 1024
+)
+
+// -----------------------------------------------------------------------------------------
+
+// Etag calculates the kodo etag (the `hash` of a file) of content `r`.
+// See https://github.com/qiniu/qetag for the algorithm.
+func Etag(r io.Reader) (etag string, err error) {
+	var blocks [][]byte
+	h := sha1.New()
+	for {
+		h.Reset()
+		n, e := io.CopyN(h, r, etagBlockSize)
+		if e != nil && e != io.EOF {
+			return "", e
+		}
+		if n > 0 || blocks == nil {
+			blocks = append(blocks, h.Sum(nil))
+		}
+		if n < etagBlockSize {
+			break
+		}
+	}
+	var ret []byte
+	if len(blocks) == 1 {
+		ret = append([]byte{0x16}, blocks[0]...)
+	} else {
+		h.Reset()
+		for _, b := range blocks {
+			h.Write(b)
+		}
+		ret = h.Sum([]byte{0x96})
+	}
+	return base64.URLEncoding.EncodeToString(ret), nil
+}
+
+// -----------------------------------------------------------------------------------------
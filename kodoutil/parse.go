@@ -2,7 +2,10 @@ package kodoutil
 
 import (
 	"io/fs"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/qiniu/x/token/protected"
 )
@@ -13,25 +16,201 @@ const (
 
 // -----------------------------------------------------------------------------------------
 
+// URL represents a kodofs url in form of "kodo:<bucketName>?<token>[&<param>=<value>...]".
+//
+// The token is a protected token (see github.com/qiniu/x/token/protected) which must carry
+// `ak` and `sk`. Other params can be put into the token or follow it as plain params:
+//   - host: download host of the bucket, eg. "https://cdn.example.com".
+//   - root: key prefix that the file system is rooted at.
+//   - https: use https to access kodo services (and the download host if it has no scheme).
+//   - region: id of the region that the bucket is located in, eg. "z0". Regions in kodo.Regions
+//     are used without querying the uc service.
+//   - cache: local directory to cache the file system.
+//   - cacheFile: cache file contents (not only directory listings) in the local directory.
+//   - offline: use the local cache only, don't access the bucket.
+//   - dirTTL, fileTTL: how long cached directory listings and files are fresh, eg. "10m".
+//   - stale: serve expired listings and files from the cache and refresh them in background.
+//   - maxBytes, maxFiles: max total size and number of cached files.
+//   - evict: policy to evict cached files, "lru" (default) or "lfu".
+//   - snapshot: tar snapshot (see cached.FS.Export) to seed a new cache directory from.
+//
+// Params of the cache are supported only if package github.com/xushiwei/kodofs/cached is
+// imported.
+type URL struct {
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Host      string
+	Root      string
+	UseHTTPS  bool
+	Region    string
+	Cache     string
+	CacheFile bool
+	Offline   bool
+	DirTTL    time.Duration
+	FileTTL   time.Duration
+	Stale     bool
+	MaxBytes  int64
+	MaxFiles  int
+	Evict     string
+	Snapshot  string
+}
+
 // url = "kodo:<bucketName>?<token>"
 func Parse(url string) (bucket, ak, sk string, err error) {
-	url = strings.TrimPrefix(url, Scheme+":")
-	parts := strings.SplitN(url, "?", 2)
-	if len(parts) != 2 {
-		err = fs.ErrPermission
+	u, err := ParseURL(url)
+	if err != nil {
 		return
 	}
-	params, err := protected.Decode(parts[1])
+	return u.Bucket, u.AccessKey, u.SecretKey, nil
+}
+
+// ParseURL parses a kodofs url in form of "kodo:<bucketName>?<token>[&<param>=<value>...]".
+func ParseURL(rawurl string) (u *URL, err error) {
+	rawurl = strings.TrimPrefix(rawurl, Scheme+":")
+	parts := strings.SplitN(rawurl, "?", 2)
+	if len(parts) != 2 {
+		return nil, fs.ErrPermission
+	}
+	token, query := parts[1], ""
+	if pos := strings.IndexByte(token, '&'); pos >= 0 {
+		token, query = token[:pos], token[pos+1:]
+	}
+	params, err := protected.Decode(token)
 	if err != nil {
 		return
 	}
-	ak, sk = params.Get("ak"), params.Get("sk")
+	ak, sk := params.Get("ak"), params.Get("sk")
 	if ak == "" || sk == "" {
-		err = fs.ErrPermission
+		return nil, fs.ErrPermission
+	}
+	plain, err := url.ParseQuery(query)
+	if err != nil {
 		return
 	}
-	bucket = parts[0]
+	for k, v := range plain {
+		if k == "ak" || k == "sk" { // credentials must be protected
+			return nil, fs.ErrPermission
+		}
+		params[k] = v
+	}
+	u = &URL{
+		Bucket:    parts[0],
+		AccessKey: ak,
+		SecretKey: sk,
+		Host:      params.Get("host"),
+		Root:      params.Get("root"),
+		Region:    params.Get("region"),
+		Cache:     params.Get("cache"),
+		Evict:     params.Get("evict"),
+		Snapshot:  params.Get("snapshot"),
+	}
+	if u.UseHTTPS, err = boolParam(params, "https"); err != nil {
+		return nil, err
+	}
+	if u.CacheFile, err = boolParam(params, "cacheFile"); err != nil {
+		return nil, err
+	}
+	if u.Offline, err = boolParam(params, "offline"); err != nil {
+		return nil, err
+	}
+	if u.Stale, err = boolParam(params, "stale"); err != nil {
+		return nil, err
+	}
+	if u.DirTTL, err = durationParam(params, "dirTTL"); err != nil {
+		return nil, err
+	}
+	if u.FileTTL, err = durationParam(params, "fileTTL"); err != nil {
+		return nil, err
+	}
+	if u.MaxBytes, err = intParam(params, "maxBytes"); err != nil {
+		return nil, err
+	}
+	maxFiles, err := intParam(params, "maxFiles")
+	if err != nil {
+		return nil, err
+	}
+	u.MaxFiles = int(maxFiles)
 	return
 }
 
+func boolParam(params url.Values, key string) (bool, error) {
+	vals, ok := params[key]
+	if !ok {
+		return false, nil
+	}
+	if len(vals) == 0 || vals[0] == "" { // "&offline" means "&offline=true"
+		return true, nil
+	}
+	return strconv.ParseBool(vals[0])
+}
+
+func durationParam(params url.Values, key string) (time.Duration, error) {
+	if val := params.Get(key); val != "" {
+		return time.ParseDuration(val)
+	}
+	return 0, nil
+}
+
+func intParam(params url.Values, key string) (int64, error) {
+	if val := params.Get(key); val != "" {
+		return strconv.ParseInt(val, 10, 64)
+	}
+	return 0, nil
+}
+
+// Encode encodes the url into form of "kodo:<bucketName>?<token>[&<param>=<value>...]".
+// Only `ak` and `sk` are protected, other params are encoded as plain params.
+func (u *URL) Encode() (string, error) {
+	secret := make(url.Values)
+	secret.Set("ak", u.AccessKey)
+	secret.Set("sk", u.SecretKey)
+	token, err := protected.Encode(secret)
+	if err != nil {
+		return "", err
+	}
+	params := make(url.Values)
+	setParam(params, "host", u.Host)
+	setParam(params, "root", u.Root)
+	setParam(params, "region", u.Region)
+	setParam(params, "cache", u.Cache)
+	setParam(params, "evict", u.Evict)
+	setParam(params, "snapshot", u.Snapshot)
+	if u.UseHTTPS {
+		params.Set("https", "true")
+	}
+	if u.CacheFile {
+		params.Set("cacheFile", "true")
+	}
+	if u.Offline {
+		params.Set("offline", "true")
+	}
+	if u.Stale {
+		params.Set("stale", "true")
+	}
+	if u.DirTTL != 0 {
+		params.Set("dirTTL", u.DirTTL.String())
+	}
+	if u.FileTTL != 0 {
+		params.Set("fileTTL", u.FileTTL.String())
+	}
+	if u.MaxBytes != 0 {
+		params.Set("maxBytes", strconv.FormatInt(u.MaxBytes, 10))
+	}
+	if u.MaxFiles != 0 {
+		params.Set("maxFiles", strconv.Itoa(u.MaxFiles))
+	}
+	ret := Scheme + ":" + u.Bucket + "?" + token
+	if len(params) > 0 {
+		ret += "&" + params.Encode()
+	}
+	return ret, nil
+}
+
+func setParam(params url.Values, key, val string) {
+	if val != "" {
+		params.Set(key, val)
+	}
+}
+
 // -----------------------------------------------------------------------------------------
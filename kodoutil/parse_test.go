@@ -0,0 +1,54 @@
+package kodoutil
+
+import (
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/qiniu/x/token/protected"
+)
+
+func init() {
+	protected.KeySalt = "kodoutil-test"
+	protected.EnvKeyName = "KODOUTIL_TEST_KEY"
+}
+
+func TestParseURL(t *testing.T) {
+	t.Setenv(protected.EnvKeyName, "random-key")
+	u := &URL{
+		Bucket: "bkt", AccessKey: "ak", SecretKey: "sk",
+		Host: "https://cdn.example.com", Root: "/site/v1", Region: "z0",
+		UseHTTPS: true, Cache: "/tmp/cache", Offline: true,
+		DirTTL: time.Minute, FileTTL: 90 * time.Second, Stale: true,
+		MaxBytes: 1 << 30, MaxFiles: 1000, Evict: "lfu", Snapshot: "/tmp/site.tar",
+	}
+	s, err := u.Encode()
+	if err != nil {
+		t.Fatal("Encode:", err)
+	}
+	ret, err := ParseURL(s)
+	if err != nil {
+		t.Fatal("ParseURL:", err)
+	}
+	if *ret != *u {
+		t.Fatalf("ParseURL: got %+v, want %+v", *ret, *u)
+	}
+	bucket, ak, sk, err := Parse(s)
+	if err != nil || bucket != "bkt" || ak != "ak" || sk != "sk" {
+		t.Fatal("Parse:", bucket, ak, sk, err)
+	}
+	if _, err = ParseURL(s + "&sk=hack"); err != fs.ErrPermission {
+		t.Fatal("ParseURL with plain sk:", err)
+	}
+	if ret, err = ParseURL(s + "&cacheFile"); err != nil || !ret.CacheFile {
+		t.Fatal("ParseURL with cacheFile:", err)
+	}
+	if s, err = (&URL{Bucket: "bkt", AccessKey: "ak", SecretKey: "sk"}).Encode(); err != nil {
+		t.Fatal("Encode:", err)
+	}
+	for _, param := range []string{"dirTTL=10", "fileTTL=1d", "maxBytes=1G", "maxFiles=x"} {
+		if _, err = ParseURL(s + "&" + param); err == nil {
+			t.Fatal("ParseURL with bad param: no error", param)
+		}
+	}
+}
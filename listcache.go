@@ -0,0 +1,204 @@
+package kodofs
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultListCacheTTL      = 10 * time.Second
+	defaultListCacheMaxBytes = 64 << 20
+
+	listItemOverhead = 64 // approximate memory usage of a fs.FileInfo, except its name
+)
+
+// -----------------------------------------------------------------------------------------
+
+// ListCacheOptions represents the options of the in-memory directory listing cache of a
+// kodofs Bucket.
+type ListCacheOptions struct {
+	// TTL is how long a directory listing is cached (default: 10s).
+	TTL time.Duration
+
+	// NegativeTTL is how long a not-found path is cached. Zero disables negative caching.
+	NegativeTTL time.Duration
+
+	// MaxBytes is the approximate memory cap of cached listings (default: 64MB). The least
+	// recently used listings are dropped when it is exceeded.
+	MaxBytes int64
+}
+
+type listEntry struct {
+	key      string
+	fis      []fs.FileInfo
+	notFound bool
+	expire   time.Time
+	size     int64
+}
+
+// listCache caches directory listings (and not-found paths) of a bucket. Concurrent
+// listings of the same directory are deduplicated.
+type listCache struct {
+	opts    ListCacheOptions
+	group   singleflight.Group
+	entries map[string]*list.Element
+	lru     *list.List // front is the most recently used
+	bytes   int64
+	mutex   sync.Mutex
+}
+
+func newListCache(opts *ListCacheOptions) *listCache {
+	p := &listCache{opts: *opts, entries: make(map[string]*list.Element), lru: list.New()}
+	if p.opts.TTL <= 0 {
+		p.opts.TTL = defaultListCacheTTL
+	}
+	if p.opts.MaxBytes <= 0 {
+		p.opts.MaxBytes = defaultListCacheMaxBytes
+	}
+	return p
+}
+
+func (p *listCache) get(key string) (e *listEntry, ok bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	elem, ok := p.entries[key]
+	if !ok {
+		return
+	}
+	e = elem.Value.(*listEntry)
+	if time.Now().After(e.expire) {
+		p.remove(elem)
+		return nil, false
+	}
+	p.lru.MoveToFront(elem)
+	return
+}
+
+func (p *listCache) put(key string, fis []fs.FileInfo) {
+	if len(fis) == 0 && key != "/" { // kodo doesn't have empty directories
+		p.putNotFound(key)
+		return
+	}
+	size := int64(len(key)) + listItemOverhead
+	for _, fi := range fis {
+		size += int64(len(fi.Name())) + listItemOverhead
+	}
+	p.add(&listEntry{key: key, fis: copyFileInfos(fis), expire: time.Now().Add(p.opts.TTL), size: size})
+}
+
+func (p *listCache) putNotFound(key string) {
+	if p.opts.NegativeTTL > 0 {
+		size := int64(len(key)) + listItemOverhead
+		p.add(&listEntry{key: key, notFound: true, expire: time.Now().Add(p.opts.NegativeTTL), size: size})
+	}
+}
+
+func (p *listCache) add(e *listEntry) {
+	if e.size > p.opts.MaxBytes {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if elem, ok := p.entries[e.key]; ok {
+		p.remove(elem)
+	}
+	p.entries[e.key] = p.lru.PushFront(e)
+	p.bytes += e.size
+	for p.bytes > p.opts.MaxBytes {
+		p.remove(p.lru.Back())
+	}
+}
+
+func (p *listCache) remove(elem *list.Element) {
+	e := p.lru.Remove(elem).(*listEntry)
+	delete(p.entries, e.key)
+	p.bytes -= e.size
+}
+
+// invalidate drops cached entries of `name` and its parent directories, since a change of
+// `name` changes their listings.
+func (p *listCache) invalidate(name string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for {
+		if elem, ok := p.entries[name]; ok {
+			p.remove(elem)
+		}
+		if name == "/" {
+			break
+		}
+		name = path.Dir(name)
+	}
+}
+
+// readdir returns the cached listing of `key`, or lists it by `readdir`. Concurrent
+// listings share one call, which is retried with the caller's ctx if it fails because the
+// ctx of the first caller is done.
+func (p *listCache) readdir(ctx context.Context, key string, readdir func(ctx context.Context) ([]fs.FileInfo, error)) ([]fs.FileInfo, error) {
+	if e, ok := p.get(key); ok {
+		if e.notFound {
+			return nil, nil
+		}
+		return copyFileInfos(e.fis), nil
+	}
+	do := func(ctx context.Context) (interface{}, error) {
+		fis, err := readdir(ctx)
+		if err == nil {
+			p.put(key, fis)
+		}
+		return fis, err
+	}
+	ret, err, _ := p.group.Do(key, func() (interface{}, error) {
+		return do(ctx)
+	})
+	if err != nil && isContextError(err) && ctx.Err() == nil {
+		ret, err = do(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return copyFileInfos(ret.([]fs.FileInfo)), nil
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// copyFileInfos copies a listing, since callers may modify it (eg. http.FileServer sorts it).
+func copyFileInfos(fis []fs.FileInfo) []fs.FileInfo {
+	if fis == nil {
+		return nil
+	}
+	return append(make([]fs.FileInfo, 0, len(fis)), fis...)
+}
+
+// -----------------------------------------------------------------------------------------
+
+// WithListCache returns a view of the bucket which caches directory listings in memory. Views
+// derived from it by Sub share the cache.
+func (b *Bucket) WithListCache(opts *ListCacheOptions) *Bucket {
+	ret := *b
+	ret.lc, ret.lcRoot = newListCache(opts), ""
+	return &ret
+}
+
+// InvalidateListCache drops cached listings of the directory `name` and its parents, and
+// the cached not-found state of `name`.
+func (b *Bucket) InvalidateListCache(name string) {
+	if b.lc != nil {
+		b.lc.invalidate(b.lcKey(name))
+	}
+}
+
+func (b *Bucket) lcKey(name string) string {
+	return path.Join(b.lcRoot, path.Clean("/"+name))
+}
+
+// -----------------------------------------------------------------------------------------
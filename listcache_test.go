@@ -0,0 +1,130 @@
+package kodofs_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xushiwei/kodofs"
+	"github.com/xushiwei/kodofs/backend"
+)
+
+type countingBackend struct {
+	backend.Backend
+	lists int32
+}
+
+func (p *countingBackend) List(ctx context.Context, prefix, delimiter, marker string, limit int) (*backend.ListResult, error) {
+	atomic.AddInt32(&p.lists, 1)
+	time.Sleep(10 * time.Millisecond) // let concurrent listings meet
+	return p.Backend.List(ctx, prefix, delimiter, marker, limit)
+}
+
+func TestListCache(t *testing.T) {
+	ctx := context.Background()
+	be := &countingBackend{Backend: backend.NewMemory()}
+	be.Put(ctx, "site/a.txt", strings.NewReader("a"), 1)
+	bkt := kodofs.FromBackend(be, "", nil).WithListCache(&kodofs.ListCacheOptions{
+		TTL:         time.Minute,
+		NegativeTTL: time.Minute,
+	})
+	site := bkt.Sub("site")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if fis, err := site.ReaddirContext(ctx, "/"); err != nil || len(fis) != 1 {
+				t.Error("ReaddirContext:", fis, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := bkt.Open("/site"); err != nil {
+		t.Fatal("Open /site:", err)
+	}
+	if n := atomic.LoadInt32(&be.lists); n != 1 {
+		t.Fatal("lists:", n)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := site.Open("/none"); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatal("Open /none:", err)
+		}
+	}
+	if n := atomic.LoadInt32(&be.lists); n != 2 {
+		t.Fatal("lists (negative caching):", n)
+	}
+
+	if err := site.Upload(ctx, "/none/b.txt", strings.NewReader("b"), 1); err != nil {
+		t.Fatal("Upload:", err)
+	}
+	if _, err := site.Open("/none"); err != nil {
+		t.Fatal("Open /none after Upload:", err)
+	}
+	if fis, err := site.ReaddirContext(ctx, "/"); err != nil || len(fis) != 2 {
+		t.Fatal("ReaddirContext after Upload:", fis, err)
+	}
+}
+
+type blockingBackend struct {
+	backend.Backend
+	started chan struct{}
+}
+
+func (p *blockingBackend) List(ctx context.Context, prefix, delimiter, marker string, limit int) (*backend.ListResult, error) {
+	select {
+	case p.started <- struct{}{}:
+	default:
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(20 * time.Millisecond):
+	}
+	return p.Backend.List(ctx, prefix, delimiter, marker, limit)
+}
+
+func TestListCacheShared(t *testing.T) {
+	ctx := context.Background()
+	be := &blockingBackend{Backend: backend.NewMemory(), started: make(chan struct{}, 1)}
+	be.Put(ctx, "a.txt", strings.NewReader("a"), 1)
+	be.Put(ctx, "b.txt", strings.NewReader("b"), 1)
+	bkt := kodofs.FromBackend(be, "", nil).WithListCache(&kodofs.ListCacheOptions{TTL: time.Minute})
+
+	// the first caller cancels while another one waits for the shared listing
+	cctx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		_, err := bkt.ReaddirContext(cctx, "/")
+		done <- err
+	}()
+	<-be.started
+	var fis []fs.FileInfo
+	var err error
+	waited := make(chan struct{})
+	go func() {
+		fis, err = bkt.ReaddirContext(ctx, "/")
+		close(waited)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+	<-done
+	<-waited
+	if err != nil || len(fis) != 2 {
+		t.Fatal("ReaddirContext of the waiter:", fis, err)
+	}
+
+	// callers can't modify the cached listing
+	fis[0], fis[1] = nil, fis[0]
+	fis, err = bkt.ReaddirContext(ctx, "/")
+	if err != nil || len(fis) != 2 || fis[0] == nil || fis[0].Name() != "a.txt" {
+		t.Fatal("ReaddirContext after modifying:", fis, err)
+	}
+}
@@ -0,0 +1,135 @@
+// Package metrics provides adapters of kodo.Metrics.
+package metrics
+
+import (
+	"expvar"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/xushiwei/kodofs/kodo"
+)
+
+var (
+	_ kodo.Metrics = (*Expvar)(nil)
+)
+
+// LatencyBuckets are upper bounds of latency histograms of Expvar.
+var LatencyBuckets = []time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// -----------------------------------------------------------------------------------------
+
+// Expvar is a kodo.Metrics which publishes metrics as an expvar.Map, so they are served by
+// the /debug/vars endpoint of expvar. The map has these counters keyed by "<class> <host>":
+//   - requests: request counts, keyed by "<class> <host> <status code>" (0 for errors)
+//   - retries: retry counts
+//   - host_freezes: host freeze counts
+//   - bytes_sent, bytes_received: bytes of request and response bodies
+//
+// and latency histograms keyed by API classes, each of which maps upper bounds of buckets
+// (see LatencyBuckets, and "+Inf") to request counts.
+type Expvar struct {
+	root          *expvar.Map
+	requests      *expvar.Map
+	retries       *expvar.Map
+	hostFreezes   *expvar.Map
+	bytesSent     *expvar.Map
+	bytesReceived *expvar.Map
+	latency       *expvar.Map
+	mutex         sync.Mutex // protects creating histograms of latency
+}
+
+// NewExpvar creates an Expvar and publishes it as `name`. Like expvar.Publish, it panics if
+// the name is already used.
+func NewExpvar(name string) *Expvar {
+	p := newExpvar()
+	expvar.Publish(name, p.root)
+	return p
+}
+
+func newExpvar() *Expvar {
+	p := &Expvar{
+		root:          new(expvar.Map).Init(),
+		requests:      new(expvar.Map).Init(),
+		retries:       new(expvar.Map).Init(),
+		hostFreezes:   new(expvar.Map).Init(),
+		bytesSent:     new(expvar.Map).Init(),
+		bytesReceived: new(expvar.Map).Init(),
+		latency:       new(expvar.Map).Init(),
+	}
+	p.root.Set("requests", p.requests)
+	p.root.Set("retries", p.retries)
+	p.root.Set("host_freezes", p.hostFreezes)
+	p.root.Set("bytes_sent", p.bytesSent)
+	p.root.Set("bytes_received", p.bytesReceived)
+	p.root.Set("latency", p.latency)
+	return p
+}
+
+// Map returns the expvar.Map of metrics.
+func (p *Expvar) Map() *expvar.Map {
+	return p.root
+}
+
+// Request implements kodo.Metrics.Request.
+func (p *Expvar) Request(class kodo.APIClass, host string, code int, latency time.Duration) {
+	p.requests.Add(key(class, host)+" "+strconv.Itoa(code), 1)
+	p.histogram(class).Add(bucketOf(latency), 1)
+}
+
+// Retry implements kodo.Metrics.Retry.
+func (p *Expvar) Retry(class kodo.APIClass, host string) {
+	p.retries.Add(key(class, host), 1)
+}
+
+// HostFrozen implements kodo.Metrics.HostFrozen.
+func (p *Expvar) HostFrozen(class kodo.APIClass, host string) {
+	p.hostFreezes.Add(key(class, host), 1)
+}
+
+// BytesSent implements kodo.Metrics.BytesSent.
+func (p *Expvar) BytesSent(class kodo.APIClass, host string, n int64) {
+	p.bytesSent.Add(key(class, host), n)
+}
+
+// BytesReceived implements kodo.Metrics.BytesReceived.
+func (p *Expvar) BytesReceived(class kodo.APIClass, host string, n int64) {
+	p.bytesReceived.Add(key(class, host), n)
+}
+
+func (p *Expvar) histogram(class kodo.APIClass) *expvar.Map {
+	name := string(class)
+	if h, ok := p.latency.Get(name).(*expvar.Map); ok {
+		return h
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if h, ok := p.latency.Get(name).(*expvar.Map); ok {
+		return h
+	}
+	h := new(expvar.Map).Init()
+	p.latency.Set(name, h)
+	return h
+}
+
+func key(class kodo.APIClass, host string) string {
+	if class == "" {
+		class = "other"
+	}
+	return string(class) + " " + host
+}
+
+func bucketOf(latency time.Duration) string {
+	for _, b := range LatencyBuckets {
+		if latency <= b {
+			return b.String()
+		}
+	}
+	return "+Inf"
+}
+
+// -----------------------------------------------------------------------------------------
@@ -0,0 +1,56 @@
+package metrics_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/xushiwei/kodofs/kodotest"
+	"github.com/xushiwei/kodofs/metrics"
+)
+
+func TestExpvar(t *testing.T) {
+	s := kodotest.NewServer("bkt", nil)
+	defer s.Close()
+
+	m := metrics.NewExpvar("kodo_test")
+	ctx := context.Background()
+	opts := s.Options()
+	opts.Metrics = m
+	bkt := s.Credentials().NewBucketEx("bkt", opts)
+	if err := bkt.Upload(ctx, "a.txt", strings.NewReader("hello"), 5); err != nil {
+		t.Fatal("Upload:", err)
+	}
+	if _, err := bkt.Stat(ctx, "a.txt"); err != nil {
+		t.Fatal("Stat:", err)
+	}
+	rc, err := bkt.Open(ctx, "a.txt", 0, -1)
+	if err != nil {
+		t.Fatal("Open:", err)
+	}
+	io.Copy(io.Discard, rc)
+	rc.Close()
+
+	var ret struct {
+		Requests      map[string]int64            `json:"requests"`
+		BytesSent     map[string]int64            `json:"bytes_sent"`
+		BytesReceived map[string]int64            `json:"bytes_received"`
+		Latency       map[string]map[string]int64 `json:"latency"`
+	}
+	if err = json.Unmarshal([]byte(m.Map().String()), &ret); err != nil {
+		t.Fatal("json.Unmarshal:", err)
+	}
+	host := strings.TrimPrefix(s.URL, "http://")
+	if ret.Requests["uc "+host+" 200"] != 1 || ret.Requests["upload "+host+" 200"] != 1 ||
+		ret.Requests["rs "+host+" 200"] != 1 || ret.Requests["io "+host+" 200"] != 1 {
+		t.Fatal("requests:", ret.Requests)
+	}
+	if ret.BytesSent["upload "+host] == 0 || ret.BytesReceived["io "+host] != 5 {
+		t.Fatal("bytes:", ret.BytesSent, ret.BytesReceived)
+	}
+	if len(ret.Latency["rs"]) == 0 {
+		t.Fatal("latency:", ret.Latency)
+	}
+}
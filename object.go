@@ -0,0 +1,90 @@
+package kodofs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"path"
+
+	xfs "github.com/qiniu/x/http/fs"
+	"github.com/xushiwei/kodofs/backend"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// objectFile implements a http.File which reads an object of a Backend lazily by ranges.
+type objectFile struct {
+	ctx  context.Context
+	be   backend.Backend
+	name string
+	fi   *xfs.FileInfo
+	r    io.ReadCloser // reading stream at offset `off`, or nil
+	off  int64
+}
+
+func openObject(ctx context.Context, be backend.Backend, name string) (*objectFile, error) {
+	info, err := be.Stat(ctx, backend.CleanKey(name))
+	if err != nil {
+		return nil, err
+	}
+	fi := xfs.NewFileInfo(path.Base(name), info.Size)
+	fi.Mtime = info.PutTime
+	return &objectFile{ctx: ctx, be: be, name: name, fi: fi}, nil
+}
+
+func (p *objectFile) Read(b []byte) (n int, err error) {
+	if p.off >= p.fi.Size() {
+		return 0, io.EOF
+	}
+	if p.r == nil {
+		if p.r, err = p.be.Open(p.ctx, backend.CleanKey(p.name), p.off, -1); err != nil {
+			return
+		}
+	}
+	n, err = p.r.Read(b)
+	p.off += int64(n)
+	return
+}
+
+func (p *objectFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += p.off
+	case io.SeekEnd:
+		offset += p.fi.Size()
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: p.name, Err: fs.ErrInvalid}
+	}
+	if offset != p.off && p.r != nil {
+		p.r.Close()
+		p.r = nil
+	}
+	p.off = offset
+	return offset, nil
+}
+
+func (p *objectFile) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: p.name, Err: fs.ErrInvalid}
+}
+
+func (p *objectFile) Stat() (fs.FileInfo, error) {
+	return p.fi, nil
+}
+
+func (p *objectFile) IsDir() bool {
+	return false
+}
+
+func (p *objectFile) FullName() string {
+	return p.name
+}
+
+func (p *objectFile) Close() error {
+	if p.r != nil {
+		return p.r.Close()
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------------------
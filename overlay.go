@@ -0,0 +1,280 @@
+package kodofs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	xfs "github.com/qiniu/x/http/fs"
+)
+
+// -----------------------------------------------------------------------------------------
+
+const (
+	// WhiteoutPrefix is the name prefix of whiteout markers. A file named ".wh.<name>" in
+	// a layer hides the file or directory `<name>` (in the same directory) of lower layers.
+	WhiteoutPrefix = ".wh."
+
+	whiteoutTTL        = 10 * time.Second
+	whiteoutMaxMarkers = 4096 // max cached markers per layer
+)
+
+// Overlay is a union file system which combines several http.FileSystem layers (such as
+// kodofs Bucket objects, cached file systems or local directories). Layers are ordered
+// from top to bottom:
+//   - Opening a file: the first layer which has it wins.
+//   - Opening or reading a directory: listings of all layers which have it are merged,
+//     and upper layers win when names conflict.
+type Overlay struct {
+	layers    []http.FileSystem
+	whiteouts []*markerCache // existence of whiteout markers per layer, nil if whiteout is off
+}
+
+// NewOverlay creates an Overlay file system. If `whiteout` is true, whiteout markers (see
+// WhiteoutPrefix) are used to hide files of lower layers, and the markers themselves can't
+// be opened. Opening a path stats whiteout markers of all its components in every layer it
+// passes, and the results (whether a marker exists or not) are cached for 10 seconds.
+func NewOverlay(whiteout bool, layers ...http.FileSystem) *Overlay {
+	p := &Overlay{layers: layers}
+	if whiteout {
+		p.whiteouts = make([]*markerCache, len(layers))
+		for i := range layers {
+			p.whiteouts[i] = &markerCache{items: make(map[string]markerItem)}
+		}
+	}
+	return p
+}
+
+// Layers returns layers of the overlay file system, from top to bottom.
+func (p *Overlay) Layers() []http.FileSystem {
+	return p.layers
+}
+
+// Open implements net/http.FileSystem.Open (https://pkg.go.dev/net/http#FileSystem).
+func (p *Overlay) Open(name string) (f http.File, err error) {
+	name = path.Clean("/" + name)
+	whiteout := p.whiteouts != nil
+	if whiteout && strings.HasPrefix(path.Base(name), WhiteoutPrefix) {
+		return nil, fs.ErrNotExist
+	}
+	ctx := context.Background()
+	var dirs []http.File
+	for i, layer := range p.layers {
+		f, err = layer.Open(name)
+		if err == nil {
+			fi, e := f.Stat()
+			if e != nil {
+				f.Close()
+				closeAll(dirs)
+				return nil, e
+			}
+			if !fi.IsDir() {
+				if dirs != nil { // a directory of upper layer hides this file
+					f.Close()
+					break
+				}
+				return
+			}
+			dirs = append(dirs, f)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			closeAll(dirs)
+			return
+		}
+		if whiteout && p.whitedOut(ctx, i, name) {
+			break
+		}
+	}
+	switch len(dirs) {
+	case 0:
+		return nil, fs.ErrNotExist
+	case 1:
+		if !whiteout {
+			return dirs[0], nil
+		}
+	}
+	lists := make([][]fs.FileInfo, len(dirs))
+	for i, dir := range dirs {
+		if lists[i], err = dir.Readdir(-1); err != nil {
+			closeAll(dirs)
+			return
+		}
+	}
+	return xfs.Dir(multiDir(dirs), p.merge(lists)), nil
+}
+
+// ReaddirContext reads the directory `dir` and merges listings of all layers which have it.
+// A layer is read by its ReaddirContext method if it has one, or by Open and Readdir.
+func (p *Overlay) ReaddirContext(ctx context.Context, dir string) (fis []fs.FileInfo, err error) {
+	dir = path.Clean("/" + dir)
+	var lists [][]fs.FileInfo
+	for i, layer := range p.layers {
+		items, e := readdirContext(ctx, layer, dir)
+		if e == nil {
+			lists = append(lists, items)
+		} else if !errors.Is(e, fs.ErrNotExist) {
+			return nil, e
+		}
+		if p.whiteouts != nil && p.whitedOut(ctx, i, dir) {
+			break
+		}
+	}
+	return p.merge(lists), nil
+}
+
+type readdirContexter interface {
+	ReaddirContext(ctx context.Context, dir string) (fis []fs.FileInfo, err error)
+}
+
+func readdirContext(ctx context.Context, layer http.FileSystem, dir string) ([]fs.FileInfo, error) {
+	if r, ok := layer.(readdirContexter); ok {
+		return r.ReaddirContext(ctx, dir)
+	}
+	f, err := layer.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdir(-1)
+}
+
+// merge merges directory listings of layers (from top to bottom).
+func (p *Overlay) merge(lists [][]fs.FileInfo) []fs.FileInfo {
+	whiteout := p.whiteouts != nil
+	if len(lists) == 1 && !whiteout {
+		return lists[0]
+	}
+	seen := make(map[string]struct{})
+	ret := make([]fs.FileInfo, 0, 64)
+	for _, items := range lists {
+		var hidden []string
+		for _, fi := range items {
+			name := fi.Name()
+			if whiteout && strings.HasPrefix(name, WhiteoutPrefix) {
+				hidden = append(hidden, name[len(WhiteoutPrefix):])
+				continue
+			}
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			ret = append(ret, fi)
+		}
+		for _, name := range hidden { // hide files of lower layers
+			seen[name] = struct{}{}
+		}
+	}
+	return ret
+}
+
+// whitedOut checks if `name` or one of its parent directories is hidden by a whiteout
+// marker in the i-th layer. It stats the marker of every path component, so a path costs
+// up to depth stats per layer when it isn't cached.
+func (p *Overlay) whitedOut(ctx context.Context, i int, name string) bool {
+	for name != "/" {
+		dir, base := path.Split(name)
+		if p.hasMarker(ctx, i, dir+WhiteoutPrefix+base) {
+			return true
+		}
+		name = path.Clean(dir)
+	}
+	return false
+}
+
+// hasMarker checks if the whiteout marker `marker` exists in the i-th layer. If the layer
+// fails to stat it, it is treated as missing and the result isn't cached.
+func (p *Overlay) hasMarker(ctx context.Context, i int, marker string) bool {
+	cache := p.whiteouts[i]
+	if exists, ok := cache.get(marker); ok {
+		return exists
+	}
+	_, err := statContext(ctx, p.layers[i], marker)
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		cache.put(marker, err == nil)
+	}
+	return err == nil
+}
+
+type statContexter interface {
+	StatContext(ctx context.Context, name string) (fs.FileInfo, error)
+}
+
+func statContext(ctx context.Context, layer http.FileSystem, name string) (fs.FileInfo, error) {
+	if s, ok := layer.(statContexter); ok {
+		return s.StatContext(ctx, name)
+	}
+	f, err := layer.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// -----------------------------------------------------------------------------------------
+
+type markerItem struct {
+	exists bool
+	expire time.Time
+}
+
+// markerCache caches whether whiteout markers of a layer exist.
+type markerCache struct {
+	items map[string]markerItem
+	mutex sync.Mutex
+}
+
+func (p *markerCache) get(marker string) (exists, ok bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	item, ok := p.items[marker]
+	if ok && time.Now().After(item.expire) {
+		delete(p.items, marker)
+		return false, false
+	}
+	return item.exists, ok
+}
+
+func (p *markerCache) put(marker string, exists bool) {
+	now := time.Now()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.items) >= whiteoutMaxMarkers {
+		for k, item := range p.items {
+			if now.After(item.expire) {
+				delete(p.items, k)
+			}
+		}
+		if len(p.items) >= whiteoutMaxMarkers {
+			p.items = make(map[string]markerItem)
+		}
+	}
+	p.items[marker] = markerItem{exists, now.Add(whiteoutTTL)}
+}
+
+// -----------------------------------------------------------------------------------------
+
+type multiDir []http.File
+
+func (p multiDir) Stat() (fs.FileInfo, error) {
+	return p[0].Stat()
+}
+
+func (p multiDir) Close() error {
+	return closeAll(p)
+}
+
+func closeAll(files []http.File) (err error) {
+	for _, f := range files {
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// -----------------------------------------------------------------------------------------
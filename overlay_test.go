@@ -0,0 +1,118 @@
+package kodofs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// countingFS counts opened names of a layer.
+type countingFS struct {
+	http.FileSystem
+	mutex sync.Mutex
+	opens map[string]int
+}
+
+func (p *countingFS) Open(name string) (http.File, error) {
+	p.mutex.Lock()
+	if p.opens == nil {
+		p.opens = make(map[string]int)
+	}
+	p.opens[name]++
+	p.mutex.Unlock()
+	return p.FileSystem.Open(name)
+}
+
+func writeFiles(t *testing.T, dir string, files ...string) {
+	for i := 0; i < len(files); i += 2 {
+		file := filepath.Join(dir, files[i])
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(files[i+1]), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	upper, lower := t.TempDir(), t.TempDir()
+	writeFiles(t, upper, "a/x.txt", "upper x", "a/.wh.z.txt", "", ".wh.hidden", "")
+	writeFiles(t, lower, "a/x.txt", "lower x", "a/y.txt", "lower y", "a/z.txt", "lower z", "hidden/w.txt", "w")
+	o := NewOverlay(true, http.Dir(upper), http.Dir(lower))
+
+	f, err := o.Open("/a/x.txt")
+	if err != nil {
+		t.Fatal("Open:", err)
+	}
+	b, _ := io.ReadAll(f)
+	f.Close()
+	if string(b) != "upper x" {
+		t.Fatal("Open /a/x.txt:", string(b))
+	}
+	if _, err = o.Open("/a/z.txt"); !os.IsNotExist(err) {
+		t.Fatal("Open whiteout file:", err)
+	}
+	if _, err = o.Open("/hidden/w.txt"); !os.IsNotExist(err) {
+		t.Fatal("Open file in whiteout dir:", err)
+	}
+	if _, err = o.Open("/a/.wh.z.txt"); !os.IsNotExist(err) {
+		t.Fatal("Open whiteout marker:", err)
+	}
+
+	fis, err := o.ReaddirContext(context.Background(), "/a")
+	if err != nil {
+		t.Fatal("ReaddirContext:", err)
+	}
+	names := make([]string, len(fis))
+	for i, fi := range fis {
+		names[i] = fi.Name()
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "x.txt" || names[1] != "y.txt" {
+		t.Fatal("ReaddirContext /a:", names)
+	}
+
+	d, err := o.Open("/")
+	if err != nil {
+		t.Fatal("Open /:", err)
+	}
+	defer d.Close()
+	if fis, err = d.Readdir(-1); err != nil || len(fis) != 1 || fis[0].Name() != "a" {
+		t.Fatal("Readdir /:", fis, err)
+	}
+}
+
+func TestOverlayWhiteoutCache(t *testing.T) {
+	upper, lower := t.TempDir(), t.TempDir()
+	writeFiles(t, upper, "a/b/c/x.txt", "upper x", "a/b/.wh.y.txt", "")
+	writeFiles(t, lower, "a/b/c/x.txt", "lower x", "a/b/y.txt", "lower y", "a/b/c/z.txt", "lower z")
+	top := &countingFS{FileSystem: http.Dir(upper)}
+	o := NewOverlay(true, top, http.Dir(lower))
+
+	for i := 0; i < 3; i++ {
+		f, err := o.Open("/a/b/c/z.txt")
+		if err != nil {
+			t.Fatal("Open:", err)
+		}
+		f.Close()
+		if _, err = o.Open("/a/b/y.txt"); !os.IsNotExist(err) {
+			t.Fatal("Open whiteout file:", err)
+		}
+	}
+	for _, marker := range []string{"/.wh.a", "/a/.wh.b", "/a/b/.wh.c", "/a/b/c/.wh.z.txt", "/a/b/.wh.y.txt"} {
+		if n := top.opens[marker]; n != 1 {
+			t.Fatalf("whiteout marker %s probed %d times", marker, n)
+		}
+	}
+	for _, dir := range []string{"/", "/a", "/a/b"} {
+		if n := top.opens[dir]; n != 0 {
+			t.Fatalf("directory %s listed %d times", dir, n)
+		}
+	}
+}